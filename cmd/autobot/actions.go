@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/mercari"
+	"github.com/xuhoa/autobot/pkg/store"
+	"github.com/xuhoa/autobot/pkg/telegram"
+)
+
+// muteDuration is how long "🔇 Mute brand" silences a brand.
+const muteDuration = 24 * time.Hour
+
+// handleAction applies a deal button pressed in Telegram and returns the
+// confirmation shown to the user.
+func (b *Bot) handleAction(ctx context.Context, a telegram.Action) (string, error) {
+	if a.Kind == telegram.ActionBlockSeller {
+		if err := b.store.BlockSeller(ctx, a.SellerID, a.ItemID); err != nil {
+			return "", err
+		}
+		log.Printf("[ACTION] 🚫 Blocked seller %s (item %s)", a.SellerID, a.ItemID)
+		return "🚫 Seller blocked", nil
+	}
+
+	// The other actions need the brand the item was announced under
+	item, err := b.store.GetSeen(ctx, a.ItemID)
+	if errors.Is(err, store.ErrNotFound) {
+		return "Item is no longer tracked", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch a.Kind {
+	case telegram.ActionWatch:
+		if err := b.store.Watch(ctx, item); err != nil {
+			return "", err
+		}
+		log.Printf("[ACTION] 👀 Watching '%s'", item.Name)
+		return "👀 Added to watch list", nil
+	case telegram.ActionMuteBrand:
+		if err := b.store.MuteBrand(ctx, item.Brand, time.Now().Add(muteDuration)); err != nil {
+			return "", err
+		}
+		log.Printf("[ACTION] 🔇 Muted %s for %s", item.Brand, muteDuration)
+		return fmt.Sprintf("🔇 %s muted for 24h", item.Brand), nil
+	case telegram.ActionNotRelevant:
+		if err := b.store.RecordFeedback(ctx, item, store.FeedbackNotRelevant); err != nil {
+			return "", err
+		}
+		log.Printf("[ACTION] 🗑 Not relevant: '%s' [%s]", item.Name, item.Brand)
+		return "🗑 Thanks, noted", nil
+	}
+	return "", fmt.Errorf("unknown action %q", a.Kind)
+}
+
+// dropBlockedSellers removes listings from sellers blocked via the
+// "🚫 Block seller" button.
+func (b *Bot) dropBlockedSellers(ctx context.Context, source string, items []mercari.Item) []mercari.Item {
+	blocked, err := b.store.BlockedSellers(ctx)
+	if err != nil {
+		log.Printf("[%s] ⚠️ Could not load blocked sellers: %v", source, err)
+		return items
+	}
+	if len(blocked) == 0 {
+		return items
+	}
+	kept := items[:0:0]
+	for _, item := range items {
+		if blocked[item.SellerID] {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/xuhoa/autobot/pkg/mercari"
+	"github.com/xuhoa/autobot/pkg/store"
+)
+
+// runBrandsCommand handles `autobot brands lookup <name>`. It uses the
+// DPoP key and database in dir but not the config, so it works before
+// any notifier is set up.
+func runBrandsCommand(ctx context.Context, args []string, dir string) error {
+	if len(args) < 2 || args[0] != "lookup" {
+		return fmt.Errorf("usage: autobot brands lookup <name>")
+	}
+
+	key, err := mercari.LoadOrCreateKey(filepath.Join(dir, dpopKeyFile), 0)
+	if err != nil {
+		return fmt.Errorf("DPoP key error: %w", err)
+	}
+	scanner, err := mercari.NewScanner(mercari.WithPrivateKey(key))
+	if err != nil {
+		return err
+	}
+	st, err := store.NewDedupStore(filepath.Join(dir, seenDBFile))
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	return lookupBrands(ctx, strings.Join(args[1:], " "), scanner, st)
+}
+
+// lookupBrands runs a live search for name, records the itemBrand of every
+// result, and prints both the brands found in that search and all
+// previously seen brands whose name matches, so their IDs can go into a
+// brand's brand_ids. Listings of any age count.
+func lookupBrands(ctx context.Context, name string, scanner *mercari.Scanner, st *store.DedupStore) error {
+	items, err := scanner.Search(ctx, mercari.SearchOptions{Keyword: name, Limit: 120, AnyAge: true})
+	if err != nil {
+		log.Printf("⚠️ Live search failed, showing stored brands only: %v", err)
+	}
+	recordBrands(ctx, st, items)
+
+	// Tally brands in the live results
+	type tally struct {
+		id, hits int
+		name     string
+	}
+	counts := make(map[int]*tally)
+	for _, item := range items {
+		if item.BrandID == 0 {
+			continue
+		}
+		if counts[item.BrandID] == nil {
+			counts[item.BrandID] = &tally{id: item.BrandID, name: item.BrandName}
+		}
+		counts[item.BrandID].hits++
+	}
+	live := make([]*tally, 0, len(counts))
+	for _, t := range counts {
+		live = append(live, t)
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].hits > live[j].hits })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\nBrands in current results for %q (%d listings):\n", name, len(items))
+	fmt.Fprintln(w, "  ID\tListings\tName")
+	for i, t := range live {
+		if i == 10 {
+			break
+		}
+		fmt.Fprintf(w, "  %d\t%d\t%s\n", t.id, t.hits, t.name)
+	}
+	if len(live) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+
+	stored, err := st.LookupBrands(ctx, name, 20)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nPreviously seen brands matching %q:\n", name)
+	fmt.Fprintln(w, "  ID\tListings\tName\tLast seen")
+	for _, b := range stored {
+		fmt.Fprintf(w, "  %d\t%d\t%s\t%s\n", b.ID, b.Hits, b.Name, b.LastSeen.Local().Format("2006-01-02"))
+	}
+	if len(stored) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	w.Flush()
+
+	fmt.Println("\nAdd the right ID to the brand in config.json, e.g. \"brand_ids\": [12345]")
+	return nil
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuhoa/autobot/config"
+	"github.com/xuhoa/autobot/pkg/store"
+	"github.com/xuhoa/autobot/pkg/telegram"
+)
+
+// commands returns the brand management and scan control commands served
+// over Telegram. Brand edits apply to the live brand list from the next
+// scan on and are saved to the SQLite brand overlay, whose keywords and
+// prices are merged onto the config file's brands on restart and reload.
+func (b *Bot) commands() map[string]telegram.CommandFunc {
+	return map[string]telegram.CommandFunc{
+		"brands":     b.cmdBrands,
+		"addbrand":   b.cmdAddBrand,
+		"addkw":      b.cmdAddKeyword,
+		"rmkw":       b.cmdRemoveKeyword,
+		"setprice":   b.cmdSetPrice,
+		"resetbrand": b.cmdResetBrand,
+		"pause":      b.cmdPause,
+		"resume":     b.cmdResume,
+		"scan":       b.cmdScan,
+	}
+}
+
+// brands returns the current brand list. The slice is never modified in
+// place (edits replace it), so callers may keep iterating it unlocked.
+func (b *Bot) brands() []config.Brand {
+	b.cfgMu.RLock()
+	defer b.cfgMu.RUnlock()
+	return b.cfg.Brands
+}
+
+// updateBrand applies edit to a copy of the named brand (matched
+// case-insensitively), swaps it into a new brand list and persists the
+// changed keywords and prices in its overlay.
+func (b *Bot) updateBrand(ctx context.Context, name string, edit func(*config.Brand) error) (config.Brand, error) {
+	b.cfgMu.Lock()
+	defer b.cfgMu.Unlock()
+
+	i := findBrand(b.cfg.Brands, name)
+	if i < 0 {
+		return config.Brand{}, fmt.Errorf("no brand named %q (see /brands)", name)
+	}
+	before := b.cfg.Brands[i]
+	brand := cloneBrand(before)
+	if err := edit(&brand); err != nil {
+		return config.Brand{}, err
+	}
+
+	o, err := b.loadOverlay(ctx, before)
+	if err != nil {
+		return config.Brand{}, err
+	}
+	if !slices.Equal(brand.Keywords, before.Keywords) {
+		o.Keywords = append([]string{}, brand.Keywords...)
+	}
+	if brand.PriceMin != before.PriceMin || brand.PriceMax != before.PriceMax {
+		pMin, pMax := brand.PriceMin, brand.PriceMax
+		o.PriceMin, o.PriceMax = &pMin, &pMax
+	}
+	if err := b.saveOverlay(ctx, o); err != nil {
+		return config.Brand{}, err
+	}
+
+	brands := append([]config.Brand(nil), b.cfg.Brands...)
+	brands[i] = brand
+	b.cfg.Brands = brands
+	return brand, nil
+}
+
+// loadOverlay returns the stored overlay of brand, or a new one based on
+// brand, which then is still the config file's entry.
+func (b *Bot) loadOverlay(ctx context.Context, brand config.Brand) (brandOverlay, error) {
+	row, ok, err := b.store.BrandOverlay(ctx, brand.Name)
+	if err != nil || !ok {
+		return brandOverlay{Name: brand.Name, File: &overlayBase{
+			Keywords: brand.Keywords, PriceMin: brand.PriceMin, PriceMax: brand.PriceMax,
+		}}, err
+	}
+	var o brandOverlay
+	if err := json.Unmarshal(row.JSON, &o); err != nil {
+		return brandOverlay{}, fmt.Errorf("reading overlay of %s: %w", brand.Name, err)
+	}
+	o.Name = brand.Name
+	return o, nil
+}
+
+func (b *Bot) saveOverlay(ctx context.Context, o brandOverlay) error {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("encoding brand overlay: %w", err)
+	}
+	return b.store.SaveBrandOverlay(ctx, o.Name, data)
+}
+
+// ---------- Commands ----------
+
+func (b *Bot) cmdBrands(ctx context.Context, args []string) string {
+	cfg := b.config()
+	brands := b.brands()
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🏷 <b>%d brands</b>\n", len(brands)))
+	for _, brand := range brands {
+		pMin, pMax := cfg.GetPriceRange(brand)
+		sb.WriteString(fmt.Sprintf("\n<b>%s</b> ¥%d-¥%d", telegram.EscapeHTML(brand.Name), pMin, pMax))
+		if len(brand.Keywords) > 0 {
+			sb.WriteString("\n  " + telegram.EscapeHTML(strings.Join(brand.Keywords, ", ")))
+		}
+		if len(brand.BrandIDs) > 0 {
+			sb.WriteString(fmt.Sprintf("\n  brand IDs: %v", brand.BrandIDs))
+		}
+		if brand.IntervalMinutes > 0 || brand.Priority != 0 || len(brand.Categories) > 0 {
+			sb.WriteString(fmt.Sprintf("\n  every %d min · priority %d · categories %v",
+				int(cfg.GetScanInterval(brand)/time.Minute), brand.Priority, cfg.GetCategories(brand)))
+		}
+	}
+	return sb.String()
+}
+
+// /addbrand <name> [keyword...]
+func (b *Bot) cmdAddBrand(ctx context.Context, args []string) string {
+	if len(args) == 0 {
+		return "Usage: /addbrand &lt;name&gt; [keyword...]\nQuote names with spaces: /addbrand \"Kapital\" KAPITAL キャピタル"
+	}
+	brand := config.Brand{Name: args[0], Keywords: dedupKeywords(args[1:])}
+	if len(brand.Keywords) == 0 {
+		brand.Keywords = []string{brand.Name}
+	}
+
+	b.cfgMu.Lock()
+	defer b.cfgMu.Unlock()
+	if findBrand(b.cfg.Brands, brand.Name) >= 0 {
+		return fmt.Sprintf("⚠️ Brand <b>%s</b> already exists", telegram.EscapeHTML(brand.Name))
+	}
+	if err := b.saveOverlay(ctx, brandOverlay{Name: brand.Name, Keywords: brand.Keywords}); err != nil {
+		return "⚠️ " + telegram.EscapeHTML(err.Error())
+	}
+	b.cfg.Brands = append(append([]config.Brand(nil), b.cfg.Brands...), brand)
+
+	log.Printf("[COMMAND] ➕ Added brand %s %v", brand.Name, brand.Keywords)
+	return fmt.Sprintf("✅ Added <b>%s</b>: %s", telegram.EscapeHTML(brand.Name), telegram.EscapeHTML(strings.Join(brand.Keywords, ", ")))
+}
+
+// /addkw <brand> <keyword...>
+func (b *Bot) cmdAddKeyword(ctx context.Context, args []string) string {
+	if len(args) < 2 {
+		return "Usage: /addkw &lt;brand&gt; &lt;keyword...&gt;"
+	}
+	brand, err := b.updateBrand(ctx, args[0], func(br *config.Brand) error {
+		br.Keywords = dedupKeywords(append(br.Keywords, args[1:]...))
+		return nil
+	})
+	return b.brandReply(brand, err, "Added keywords")
+}
+
+// /rmkw <brand> <keyword...>
+func (b *Bot) cmdRemoveKeyword(ctx context.Context, args []string) string {
+	if len(args) < 2 {
+		return "Usage: /rmkw &lt;brand&gt; &lt;keyword...&gt;"
+	}
+	brand, err := b.updateBrand(ctx, args[0], func(br *config.Brand) error {
+		remove := make(map[string]bool)
+		for _, kw := range args[1:] {
+			remove[strings.ToLower(kw)] = true
+		}
+		var kept []string
+		for _, kw := range br.Keywords {
+			if !remove[strings.ToLower(kw)] {
+				kept = append(kept, kw)
+			}
+		}
+		if len(kept) == len(br.Keywords) {
+			return fmt.Errorf("%s has no such keyword", br.Name)
+		}
+		if len(kept) == 0 && len(br.BrandIDs) == 0 {
+			return fmt.Errorf("%s would have no keywords left", br.Name)
+		}
+		br.Keywords = kept
+		return nil
+	})
+	return b.brandReply(brand, err, "Removed keywords")
+}
+
+// /setprice <brand> <min> <max>
+func (b *Bot) cmdSetPrice(ctx context.Context, args []string) string {
+	if len(args) != 3 {
+		return "Usage: /setprice &lt;brand&gt; &lt;min&gt; &lt;max&gt; (0 = global default)"
+	}
+	pMin, errMin := strconv.Atoi(strings.ReplaceAll(args[1], ",", ""))
+	pMax, errMax := strconv.Atoi(strings.ReplaceAll(args[2], ",", ""))
+	if errMin != nil || errMax != nil || pMin < 0 || pMax < 0 || (pMax > 0 && pMin > pMax) {
+		return "⚠️ Prices must be whole yen amounts with min ≤ max"
+	}
+	brand, err := b.updateBrand(ctx, args[0], func(br *config.Brand) error {
+		br.PriceMin, br.PriceMax = pMin, pMax
+		return nil
+	})
+	return b.brandReply(brand, err, "Price range updated")
+}
+
+// /resetbrand <brand>
+func (b *Bot) cmdResetBrand(ctx context.Context, args []string) string {
+	if len(args) != 1 {
+		return "Usage: /resetbrand &lt;brand&gt; (drops its Telegram edits)"
+	}
+	name := args[0]
+	b.cfgMu.RLock()
+	if i := findBrand(b.cfg.Brands, name); i >= 0 {
+		name = b.cfg.Brands[i].Name
+	}
+	b.cfgMu.RUnlock()
+
+	dropped, err := b.store.DeleteBrandOverlay(ctx, name)
+	if err != nil {
+		return "⚠️ " + telegram.EscapeHTML(err.Error())
+	}
+	if !dropped {
+		return fmt.Sprintf("⚠️ <b>%s</b> has no Telegram edits", telegram.EscapeHTML(name))
+	}
+	// The reload puts the config file's entry back (or removes an added brand)
+	b.requestReload()
+
+	log.Printf("[COMMAND] ↩️ Dropped the Telegram edits of %s", name)
+	return fmt.Sprintf("✅ Dropped the Telegram edits of <b>%s</b>; the config file applies again from the next scan", telegram.EscapeHTML(name))
+}
+
+func (b *Bot) brandReply(brand config.Brand, err error, done string) string {
+	if err != nil {
+		return "⚠️ " + telegram.EscapeHTML(err.Error())
+	}
+	log.Printf("[COMMAND] ✏️ %s: %s", brand.Name, done)
+	pMin, pMax := b.config().GetPriceRange(brand)
+	return fmt.Sprintf("✅ %s for <b>%s</b>\n¥%d-¥%d · %s", done, telegram.EscapeHTML(brand.Name),
+		pMin, pMax, telegram.EscapeHTML(strings.Join(brand.Keywords, ", ")))
+}
+
+// ---------- Helpers ----------
+
+// brandOverlay is a brand edit made over Telegram. Only the settings the
+// commands change are kept and merged onto the config entry of the same
+// name, so later file edits to the brand's other settings still apply.
+type brandOverlay struct {
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords"` // nil keeps the file's
+	PriceMin *int     `json:"price_min,omitempty"`
+	PriceMax *int     `json:"price_max,omitempty"`
+
+	// The file entry when the brand was first edited, to notice file
+	// changes the overlay hides. Nil for brands added with /addbrand.
+	File *overlayBase `json:"file,omitempty"`
+}
+
+type overlayBase struct {
+	Keywords []string `json:"keywords"`
+	PriceMin int      `json:"price_min"`
+	PriceMax int      `json:"price_max"`
+}
+
+// apply sets the overlay's settings on brand.
+func (o brandOverlay) apply(brand *config.Brand) {
+	if o.Keywords != nil {
+		brand.Keywords = append([]string(nil), o.Keywords...)
+	}
+	if o.PriceMin != nil {
+		brand.PriceMin = *o.PriceMin
+	}
+	if o.PriceMax != nil {
+		brand.PriceMax = *o.PriceMax
+	}
+}
+
+// shadowed lists the settings of the file entry that changed since the
+// brand was edited but are still overridden by the overlay.
+func (o brandOverlay) shadowed(file config.Brand) []string {
+	if o.File == nil {
+		return nil
+	}
+	var fields []string
+	if o.Keywords != nil && !slices.Equal(file.Keywords, o.File.Keywords) {
+		fields = append(fields, "keywords")
+	}
+	if (o.PriceMin != nil || o.PriceMax != nil) && (file.PriceMin != o.File.PriceMin || file.PriceMax != o.File.PriceMax) {
+		fields = append(fields, "price range")
+	}
+	return fields
+}
+
+// applyBrandOverlays merges brands edited or added over Telegram into cfg:
+// an overlay's keywords and prices replace those of the config entry of
+// the same name, brands missing from the config are appended. Edits that
+// hide a later change to the file are logged.
+func applyBrandOverlays(ctx context.Context, cfg *config.Config, st *store.DedupStore) error {
+	rows, err := st.BrandOverlays(ctx)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		o := brandOverlay{Name: row.Name}
+		if err := json.Unmarshal(row.JSON, &o); err != nil {
+			log.Printf("[COMMAND] ⚠️ Skipping unreadable overlay for %s: %v", row.Name, err)
+			continue
+		}
+		o.Name = row.Name
+
+		i := findBrand(cfg.Brands, o.Name)
+		if i < 0 {
+			if o.File != nil {
+				log.Printf("[CONFIG] ⚠️ %s is no longer in the config file; kept because of its Telegram edits (/resetbrand %s drops them)", o.Name, o.Name)
+			}
+			brand := config.Brand{Name: o.Name}
+			o.apply(&brand)
+			cfg.Brands = append(cfg.Brands, brand)
+			continue
+		}
+		if fields := o.shadowed(cfg.Brands[i]); len(fields) > 0 {
+			log.Printf("[CONFIG] ⚠️ %s: %s changed in the config file but a Telegram edit overrides it (/resetbrand %s drops the edit)",
+				o.Name, strings.Join(fields, " and "), o.Name)
+		}
+		brand := cloneBrand(cfg.Brands[i])
+		o.apply(&brand)
+		cfg.Brands[i] = brand
+	}
+	if len(rows) > 0 {
+		log.Printf("✅ Applied %d runtime brand edit(s) from the database", len(rows))
+	}
+	return nil
+}
+
+func findBrand(brands []config.Brand, name string) int {
+	for i, brand := range brands {
+		if strings.EqualFold(brand.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// cloneBrand copies a brand so edits never touch slices shared with a
+// brand list a scan may be reading.
+func cloneBrand(brand config.Brand) config.Brand {
+	brand.Keywords = append([]string(nil), brand.Keywords...)
+	return brand
+}
+
+// dedupKeywords trims keywords and drops empty and repeated ones
+// (case-insensitively), keeping the first spelling.
+func dedupKeywords(keywords []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, kw := range keywords {
+		kw = strings.TrimSpace(kw)
+		if kw == "" || seen[strings.ToLower(kw)] {
+			continue
+		}
+		seen[strings.ToLower(kw)] = true
+		out = append(out, kw)
+	}
+	return out
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xuhoa/autobot/config"
+)
+
+// runConfigCommand handles `autobot config validate [path]`: it loads the
+// config exactly like the bot does and prints every problem found.
+func runConfigCommand(args []string, cfgPath string) error {
+	if len(args) == 0 || len(args) > 2 || args[0] != "validate" {
+		return fmt.Errorf("usage: autobot config validate [path]")
+	}
+	if len(args) == 2 {
+		cfgPath = resolveConfigPath(args[1])
+	}
+
+	cfg, err := config.LoadConfig(cfgPath)
+	var problems config.ValidationError
+	if errors.As(err, &problems) {
+		fmt.Printf("%s: %d problem(s)\n", cfgPath, len(problems))
+		for _, p := range problems {
+			fmt.Println("  " + p.String())
+		}
+		return fmt.Errorf("%s is invalid", cfgPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ %s is valid: %d brands, %d sellers, notifiers %v\n",
+		cfgPath, len(cfg.Brands), len(cfg.Sellers), cfg.Notifiers)
+	return nil
+}
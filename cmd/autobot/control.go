@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/telegram"
+)
+
+// statePausedUntil is the bot_state key holding an active /pause.
+const statePausedUntil = "paused_until"
+
+// pauseIndefinite marks a /pause without a duration.
+var pauseIndefinite = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// scanRequest asks the run loop for an out-of-band cycle; brand limits it
+// to one brand, "" scans everything.
+type scanRequest struct {
+	brand string
+}
+
+// paused reports whether scheduled scans are paused, and until when.
+func (b *Bot) paused() (bool, time.Time) {
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+	return time.Now().Before(b.pausedUntil), b.pausedUntil
+}
+
+// setPause pauses scheduled scans until the given time (zero resumes)
+// and persists it, so a restart does not silently resume.
+func (b *Bot) setPause(ctx context.Context, until time.Time) error {
+	value := ""
+	if !until.IsZero() {
+		value = until.UTC().Format(time.RFC3339)
+	}
+	if err := b.store.SetState(ctx, statePausedUntil, value); err != nil {
+		return err
+	}
+	b.stateMu.Lock()
+	b.pausedUntil = until
+	b.stateMu.Unlock()
+	return nil
+}
+
+// loadPause restores a pause saved before the last restart.
+func (b *Bot) loadPause(ctx context.Context) {
+	value, err := b.store.GetState(ctx, statePausedUntil)
+	if err != nil || value == "" {
+		return
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil || time.Now().After(until) {
+		return
+	}
+	b.stateMu.Lock()
+	b.pausedUntil = until
+	b.stateMu.Unlock()
+	log.Printf("⏸ Scans paused (%s)", pauseLabel(until))
+}
+
+// runRequested runs a /scan request from the run loop.
+func (b *Bot) runRequested(ctx context.Context, req scanRequest) {
+	if req.brand == "" {
+		b.runScanCycle(ctx)
+		return
+	}
+	brands := b.brands()
+	i := findBrand(brands, req.brand)
+	if i < 0 {
+		return // removed since the request was queued
+	}
+
+	start := time.Now()
+	log.Printf("🔍 ON-DEMAND SCAN — %s", brands[i].Name)
+	b.markScanned(start, brands[i:i+1], false)
+	b.resetClaims()
+	found, newItems, sent := b.scanBrand(ctx, brands[i])
+	log.Printf("📊 [%s] found=%d new=%d sent=%d (%.1fs)",
+		brands[i].Name, found, newItems, sent, time.Since(start).Seconds())
+}
+
+// ---------- Commands ----------
+
+// /pause [duration], e.g. /pause 2h, /pause 1d
+func (b *Bot) cmdPause(ctx context.Context, args []string) string {
+	until := pauseIndefinite
+	if len(args) > 0 {
+		d, err := parsePauseDuration(args[0])
+		if err != nil {
+			return "Usage: /pause [duration], e.g. /pause 90m, /pause 2h, /pause 1d"
+		}
+		until = time.Now().Add(d)
+	}
+	if err := b.setPause(ctx, until); err != nil {
+		return "⚠️ " + telegram.EscapeHTML(err.Error())
+	}
+	log.Printf("[COMMAND] ⏸ Scans paused (%s)", pauseLabel(until))
+	return "⏸ Scans paused " + pauseLabel(until) + ". /resume to continue."
+}
+
+func (b *Bot) cmdResume(ctx context.Context, args []string) string {
+	if ok, _ := b.paused(); !ok {
+		return "▶️ Scans are not paused"
+	}
+	if err := b.setPause(ctx, time.Time{}); err != nil {
+		return "⚠️ " + telegram.EscapeHTML(err.Error())
+	}
+	log.Println("[COMMAND] ▶️ Scans resumed")
+	return "▶️ Scans resumed"
+}
+
+// /scan [brand]
+func (b *Bot) cmdScan(ctx context.Context, args []string) string {
+	req := scanRequest{}
+	if len(args) > 0 {
+		name := strings.Join(args, " ")
+		i := findBrand(b.brands(), name)
+		if i < 0 {
+			return fmt.Sprintf("⚠️ No brand named %q (see /brands)", telegram.EscapeHTML(name))
+		}
+		req.brand = b.brands()[i].Name
+	}
+
+	select {
+	case b.scanReqs <- req:
+	default:
+		return "⏳ A scan is already queued"
+	}
+	if req.brand != "" {
+		return fmt.Sprintf("🔍 Scanning <b>%s</b> now", telegram.EscapeHTML(req.brand))
+	}
+	return "🔍 Scanning all brands now"
+}
+
+// ---------- Helpers ----------
+
+// parsePauseDuration accepts Go durations ("90m", "2h30m") and whole days ("1d").
+func parsePauseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+func pauseLabel(until time.Time) string {
+	if !until.Before(pauseIndefinite) {
+		return "until /resume"
+	}
+	return "until " + until.Local().Format("Jan 2 15:04")
+}
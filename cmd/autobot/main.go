@@ -2,7 +2,7 @@
 //
 // Inspired by PicoClaw's ultra-lightweight architecture.
 // Scans Mercari JP for designer brand deals, filters trash via AI (CLIP),
-// and sends alerts to Telegram. Designed for 24/7 Raspberry Pi operation.
+// and sends alerts to Telegram and/or Discord. Designed for 24/7 Raspberry Pi operation.
 //
 // Usage:
 //
@@ -10,9 +10,14 @@
 //	go run ./cmd/autobot/ --once              # single scan cycle
 //	go run ./cmd/autobot/ --test-telegram     # test Telegram connection
 //	go run ./cmd/autobot/ --config path.json  # custom config path
+//	go run ./cmd/autobot/ --rotate-key        # replace the stored DPoP key and exit
+//	go run ./cmd/autobot/ brands lookup Y's   # find Mercari brand IDs for brand_ids
+//	go run ./cmd/autobot/ config validate     # list every problem in the config
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -21,13 +26,18 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/xuhoa/autobot/config"
+	"github.com/xuhoa/autobot/pkg/clock"
+	"github.com/xuhoa/autobot/pkg/discord"
 	"github.com/xuhoa/autobot/pkg/mercari"
+	"github.com/xuhoa/autobot/pkg/notify"
 	"github.com/xuhoa/autobot/pkg/store"
 	"github.com/xuhoa/autobot/pkg/telegram"
+	"github.com/xuhoa/autobot/pkg/webhook"
 )
 
 const (
@@ -35,44 +45,122 @@ const (
 	logo    = "🤖"
 )
 
+// Files kept next to the config
+const (
+	dpopKeyFile = "autobot_dpop.pem"
+	seenDBFile  = "autobot_seen.db"
+)
+
 func main() {
 	// Parse flags
-	configPath := flag.String("config", "config.json", "Path to config.json")
+	configPath := flag.String("config", "config.json", "Path to the config file (.json, .yaml or .toml)")
 	once := flag.Bool("once", false, "Run one scan cycle and exit")
 	testTg := flag.Bool("test-telegram", false, "Send a test Telegram message and exit")
+	rotateKey := flag.Bool("rotate-key", false, "Generate a new DPoP key pair and exit")
 	flag.Parse()
 
+	// Ctrl+C / SIGTERM cancel ctx, which aborts in-flight requests and sleeps
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Banner
 	fmt.Printf("\n%s AutoBot v%s — Mercari Deal Hunter\n", logo, version)
 	fmt.Printf("   Platform: %s/%s | PID: %d\n\n", runtime.GOOS, runtime.GOARCH, os.Getpid())
 
 	// Load config
 	cfgPath := resolveConfigPath(*configPath)
+	switch flag.Arg(0) {
+	case "":
+	case "config":
+		if err := runConfigCommand(flag.Args()[1:], cfgPath); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		return
+	case "brands":
+		// Needs no valid config, only the key and database next to it
+		if err := runBrandsCommand(ctx, flag.Args()[1:], filepath.Dir(cfgPath)); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		return
+	default:
+		log.Fatalf("❌ unknown command %q", flag.Arg(0))
+	}
+
+	// DPoP key pair lives next to the dedup DB so restarts keep the same identity
+	keyPath := filepath.Join(filepath.Dir(cfgPath), dpopKeyFile)
+	if *rotateKey {
+		// Needs no valid config either
+		if _, err := mercari.RotateKey(keyPath); err != nil {
+			log.Fatalf("❌ Key rotation failed: %v", err)
+		}
+		log.Printf("✅ New DPoP key written to %s", keyPath)
+		return
+	}
+
 	cfg, err := config.LoadConfig(cfgPath)
 	if err != nil {
 		log.Fatalf("❌ Config error: %v", err)
 	}
-	log.Printf("✅ Config loaded: %d brands, scan every %d min, price ¥%d-¥%d",
-		len(cfg.Brands), cfg.ScanIntervalMin, cfg.PriceMin, cfg.PriceMax)
+	logConfig(cfgPath, cfg)
+
+	dpopKey, err := mercari.LoadOrCreateKey(keyPath, keyRotation(cfg))
+	if err != nil {
+		log.Fatalf("❌ DPoP key error: %v", err)
+	}
 
 	// Init components
-	scanner := mercari.NewScanner()
+	scanner, err := mercari.NewScanner(
+		mercari.WithPrivateKey(dpopKey),
+		mercari.WithRateLimiter(mercari.NewRateLimiter(cfg.RequestsPerSecond, cfg.RequestBurst)),
+		mercari.WithMaxPages(cfg.MaxPages),
+		mercari.WithMaxAge(time.Duration(cfg.MaxAgeMinutes)*time.Minute),
+	)
+	if err != nil {
+		log.Fatalf("❌ Scanner error: %v", err)
+	}
 	filter := mercari.NewAIFilter(cfg.HuggingFace.APIKey, cfg.HuggingFace.Model, cfg.EnableAIFilter)
-	notifier := telegram.NewNotifier(cfg.Telegram.BotToken, cfg.Telegram.ChatID)
 
 	// Init dedup store (SQLite)
-	dbPath := filepath.Join(filepath.Dir(cfgPath), "autobot_seen.db")
+	dbPath := filepath.Join(filepath.Dir(cfgPath), seenDBFile)
 	dedupStore, err := store.NewDedupStore(dbPath)
 	if err != nil {
 		log.Fatalf("❌ Database error: %v", err)
 	}
 	defer dedupStore.Close()
-	log.Printf("✅ Dedup store: %s (%d items tracked)", dbPath, dedupStore.Count())
+	log.Printf("✅ Dedup store: %s (%d items tracked)", dbPath, dedupStore.Count(ctx))
+	if err := applyBrandOverlays(ctx, cfg, dedupStore); err != nil {
+		log.Fatalf("❌ Brand overlay error: %v", err)
+	}
+
+	// Alert destinations; Telegram also serves /check when enabled
+	var notifiers notify.Multi
+	var tg *telegram.Notifier
+	if cfg.HasNotifier(config.NotifierTelegram) {
+		tg = telegram.NewNotifier(cfg.Telegram.BotToken, cfg.Telegram.ChatID,
+			telegram.WithCommandChats(cfg.CommandChats()...),
+			telegram.WithOnSent(recordAlert(dedupStore)))
+		notifiers = append(notifiers, tg)
+	}
+	if cfg.HasNotifier(config.NotifierDiscord) {
+		notifiers = append(notifiers, discord.NewNotifier(cfg.Discord.WebhookURL))
+	}
+	var hooks []*webhook.Notifier
+	if cfg.HasNotifier(config.NotifierWebhook) {
+		for _, w := range cfg.Webhooks {
+			hook := webhook.NewNotifier(w.URL, w.Secret, webhook.WithFailureStore(dedupStore))
+			hooks = append(hooks, hook)
+			notifiers = append(notifiers, hook)
+		}
+	}
+	log.Printf("✅ Notifiers: %v", cfg.Notifiers)
 
 	// Test Telegram mode
 	if *testTg {
+		if tg == nil {
+			log.Fatalf("❌ Telegram is not among the configured notifiers")
+		}
 		log.Println("📤 Sending test message to Telegram...")
-		if err := notifier.TestConnection(); err != nil {
+		if err := tg.TestConnection(ctx); err != nil {
 			log.Fatalf("❌ Telegram test failed: %v", err)
 		}
 		log.Println("✅ Telegram test successful!")
@@ -82,252 +170,635 @@ func main() {
 	// Create the bot
 	bot := &Bot{
 		cfg:      cfg,
+		cfgPath:  cfgPath,
 		scanner:  scanner,
 		filter:   filter,
-		notifier: notifier,
+		notifier: notifiers,
+		telegram: tg,
+		webhooks: hooks,
 		store:    dedupStore,
 	}
 
 	if *once {
 		// Single scan
 		log.Println("🔍 Running single scan cycle...")
-		bot.runScanCycle()
+		bot.runScanCycle(ctx)
 		return
 	}
 
+	// SIGHUP reloads the config, like editing it does
+	bot.reloadReqs = make(chan struct{}, 1)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Println("[CONFIG] SIGHUP received")
+			bot.requestReload()
+		}
+	}()
+
 	// Main loop with panic recovery
 	bot.startTime = time.Now()
-	bot.run()
+	bot.run(ctx)
 }
 
 // Bot holds all components and runs the main scan loop.
 type Bot struct {
 	cfg      *config.Config
+	cfgMu    sync.RWMutex // guards cfg (replaced by reloads) and cfg.Brands (edited by commands)
+	cfgPath  string       // watched for hot reload; "" disables it
 	scanner  *mercari.Scanner
 	filter   *mercari.AIFilter
-	notifier *telegram.Notifier
+	notifier notify.Notifier
+	telegram *telegram.Notifier  // nil unless Telegram is enabled; serves /check
+	webhooks []*webhook.Notifier // also in notifier; failed deliveries replayed each cycle
 	store    *store.DedupStore
 
+	// Items claimed by a worker during the current cycle, so two brands
+	// matching the same listing do not both announce it
+	claimMu sync.Mutex
+	claimed map[string]bool
+
+	// Scan loop control from Telegram: /pause state and /scan requests.
+	// The run loop is the only goroutine that scans, so cycles never overlap.
+	// stateMu also guards the status counters read by /check.
+	stateMu     sync.Mutex
+	pausedUntil time.Time
+	scanReqs    chan scanRequest
+	reloadReqs  chan struct{} // config file changed, SIGHUP or /resetbrand
+
+	// When each brand (and the sellers) was last scanned; run loop only
+	lastScanned map[string]time.Time
+
+	// When each brand's sold-price refresh last failed; run loop only
+	marketFailed map[string]time.Time
+
 	// Status tracking
 	startTime    time.Time
 	lastScanTime time.Time
 	runCount     int
 }
 
-// run starts the main bot loop with graceful shutdown.
-func (b *Bot) run() {
+// run starts the main bot loop; it returns once ctx is cancelled
+// (SIGINT/SIGTERM), after the current cycle has wound down.
+func (b *Bot) run(ctx context.Context) {
+	b.scanReqs = make(chan scanRequest, 1)
+	if b.reloadReqs == nil {
+		b.reloadReqs = make(chan struct{}, 1)
+	}
+	b.loadPause(ctx)
+	if b.cfgPath != "" {
+		go b.watchConfig(ctx)
+	}
+
 	// Send startup notification
-	if err := b.notifier.SendStartup(len(b.cfg.Brands), b.cfg.ScanIntervalMin); err != nil {
+	if err := b.notifier.SendStartup(ctx, len(b.brands()), b.cfg.ScanIntervalMin); err != nil {
 		log.Printf("⚠️ Failed to send startup notification: %v", err)
 	}
 
-	// Setup graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
-	// Start Telegram command listener (for /check)
-	// Create a separate stop channel for the listener since it runs in a goroutine
-	listenerStop := make(chan struct{})
-	go b.notifier.ListenForCommands(listenerStop, b.getStatus)
-	defer close(listenerStop)
-
-	ticker := time.NewTicker(time.Duration(b.cfg.ScanIntervalMin) * time.Minute)
-	defer ticker.Stop()
+	// Start Telegram command listener (for /check); it stops with ctx
+	if b.telegram != nil {
+		go b.telegram.ListenForCommands(ctx, telegram.Handlers{
+			Status:   b.getStatus,
+			Action:   b.handleAction,
+			Commands: b.commands(),
+		})
+		go b.recheckLoop(ctx)
+	}
 
 	// Run first scan immediately
-	log.Println("🚀 Starting first scan...")
-	b.safeScan()
+	if paused, until := b.paused(); paused {
+		log.Printf("⏸ Paused %s, skipping first scan", pauseLabel(until))
+		b.markScanned(time.Now(), b.brands(), true)
+	} else {
+		log.Println("🚀 Starting first scan...")
+		b.safeScan(ctx, b.runScanCycle)
+	}
 
-	log.Printf("⏰ Next scan in %d minutes. Press Ctrl+C to stop.", b.cfg.ScanIntervalMin)
+	// Each brand is scanned on its own interval; the timer fires when the
+	// next one is due (see schedule.go).
+	timer := time.NewTimer(time.Until(b.nextDue()))
+	defer timer.Stop()
+	log.Printf("⏰ Next scan in %s. Press Ctrl+C to stop.", time.Until(b.nextDue()).Round(time.Second))
 
 	for {
 		select {
-		case <-ticker.C:
-			b.safeScan()
-			log.Printf("⏰ Next scan in %d minutes.", b.cfg.ScanIntervalMin)
-		case sig := <-quit:
-			log.Printf("\n🛑 Received %s, shutting down gracefully...", sig)
+		case <-timer.C:
+			b.safeScan(ctx, b.runDue)
+			log.Printf("⏰ Next scan in %s.", time.Until(b.nextDue()).Round(time.Second))
+		case req := <-b.scanReqs:
+			// Out-of-band /scan; runs even while paused
+			b.safeScan(ctx, func(ctx context.Context) { b.runRequested(ctx, req) })
+		case <-b.reloadReqs:
+			if b.reloadConfig(ctx) {
+				log.Printf("⏰ Scan interval is now %d minutes.", b.cfg.ScanIntervalMin)
+			}
+		case <-ctx.Done():
+			log.Println("🛑 Shutting down gracefully...")
 			return
 		}
+		// Intervals may have changed (reload, /addbrand) or a scan run early
+		resetTimer(timer, time.Until(b.nextDue()))
 	}
 }
 
-// safeScan wraps runScanCycle with panic recovery.
-func (b *Bot) safeScan() {
+// safeScan runs a scan (usually runScanCycle) with panic recovery.
+func (b *Bot) safeScan(ctx context.Context, scan func(context.Context)) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("🔴 PANIC RECOVERED: %v", r)
-			// Try to notify via Telegram
-			_ = b.notifier.SendError(fmt.Sprintf("Panic: %v", r))
+			// Try to notify the configured destinations
+			_ = b.notifier.SendError(ctx, fmt.Sprintf("Panic: %v", r))
 			// Wait before next cycle to avoid crash-loop
-			time.Sleep(30 * time.Second)
+			_ = clock.Sleep(ctx, 30*time.Second)
 		}
 	}()
-	b.runScanCycle()
+	scan(ctx)
 }
 
-// runScanCycle performs one complete scan of all brands.
-func (b *Bot) runScanCycle() {
+// runScanCycle scans every brand and watched seller, due or not (first
+// scan, /scan, --once).
+func (b *Bot) runScanCycle(ctx context.Context) {
+	b.scanCycle(ctx, b.brands(), true)
+}
+
+// scanCycle performs one scan of the given brands, highest priority first,
+// plus the watched sellers if sellers is set. If ctx is cancelled
+// mid-cycle, workers stop picking up brands and the cycle ends with a
+// partial summary.
+func (b *Bot) scanCycle(ctx context.Context, brands []config.Brand, sellers bool) {
 	start := time.Now()
 	totalFound := 0
 	totalNew := 0
 	totalSent := 0
+	brands = byPriority(brands)
+	b.markScanned(start, brands, sellers)
 
 	log.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	log.Printf("🔍 SCAN CYCLE START — %s", start.Format("15:04:05"))
+	log.Printf("🔍 SCAN CYCLE START — %s (%d brands)", start.Format("15:04:05"), len(brands))
+
+	b.rotateKeyIfDue()
+
+	// Keep sold-price history fresh for market scoring
+	b.refreshMarket(ctx)
+	for _, hook := range b.webhooks {
+		hook.Replay(ctx)
+	}
+	totalSent += b.resendUndelivered(ctx)
+
+	// Brands and watched sellers are scanned by a bounded worker pool;
+	// the scanner's shared rate limiter keeps the total request rate polite.
+	var tasks []func() (found, newItems, sent int)
+	for _, brand := range brands {
+		brand := brand
+		tasks = append(tasks, func() (int, int, int) { return b.scanBrand(ctx, brand) })
+	}
+	if sellers {
+		for _, seller := range b.cfg.Sellers {
+			seller := seller
+			tasks = append(tasks, func() (int, int, int) { return b.scanSeller(ctx, seller) })
+		}
+	}
+
+	b.resetClaims()
 
-	// Sequential scanning (safety first)
-	for _, brand := range b.cfg.Brands {
-		found, newItems, sent := b.scanBrand(brand)
-		totalFound += found
-		totalNew += newItems
-		totalSent += sent
+	workers := b.cfg.ScanWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	queue := make(chan func() (int, int, int))
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				if ctx.Err() != nil {
+					continue // drain the queue without scanning
+				}
+				found, newItems, sent := task()
+				mu.Lock()
+				totalFound += found
+				totalNew += newItems
+				totalSent += sent
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, task := range tasks {
+		queue <- task
+	}
+	close(queue)
+	wg.Wait()
 
-		// Small delay between brands to be polite and avoid rate limits
-		// Random delay between 0.5s and 2.0s
-		jitter := time.Duration(500+rand.Intn(1500)) * time.Millisecond
-		time.Sleep(jitter)
+	// Listings the searches aged out of may still get cheaper
+	if ctx.Err() == nil {
+		totalSent += b.recheckPrices(ctx, start, b.scannedSources(ctx, brands, sellers))
 	}
 
 	duration := time.Since(start)
+	if ctx.Err() != nil {
+		log.Printf("📊 SCAN INTERRUPTED (partial): found=%d new=%d sent=%d (%.1fs)",
+			totalFound, totalNew, totalSent, duration.Seconds())
+		log.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		return
+	}
 	log.Printf("📊 SCAN COMPLETE: found=%d new=%d sent=%d (%.1fs)",
 		totalFound, totalNew, totalSent, duration.Seconds())
 	log.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
 	if totalNew > 0 {
-		_ = b.notifier.SendScanSummary(totalFound, totalNew, totalSent, duration)
+		_ = b.notifier.SendScanSummary(ctx, totalFound, totalNew, totalSent, duration)
 	}
 
+	b.stateMu.Lock()
 	b.lastScanTime = time.Now()
 	b.runCount++
+	b.stateMu.Unlock()
 }
 
 func (b *Bot) getStatus() string {
+	b.stateMu.Lock()
+	lastScanTime, runCount := b.lastScanTime, b.runCount
+	b.stateMu.Unlock()
+
 	uptime := time.Since(b.startTime).Round(time.Second)
 	lastScan := "Never"
-	if !b.lastScanTime.IsZero() {
-		lastScan = time.Since(b.lastScanTime).Round(time.Second).String() + " ago"
+	if !lastScanTime.IsZero() {
+		lastScan = time.Since(lastScanTime).Round(time.Second).String() + " ago"
+	}
+
+	state := "✅ <b>Running</b>"
+	if paused, until := b.paused(); paused {
+		state = "⏸ <b>Paused</b> " + pauseLabel(until)
 	}
 
 	return fmt.Sprintf(
 		"🤖 <b>AutoBot Status</b>\n\n"+
-			"✅ <b>Running</b>\n"+
+			"%s\n"+
 			"⏳ Uptime: %s\n"+
 			"🔄 Cycles: %d\n"+
 			"🕒 Last scan: %s\n"+
 			"📦 Items tracked: %d",
+		state,
 		uptime,
-		b.runCount,
+		runCount,
 		lastScan,
-		b.store.Count(),
+		b.store.Count(context.Background()),
 	)
 }
 
 // scanBrand searches for a single brand across all its keywords.
-func (b *Bot) scanBrand(brand config.Brand) (found, newItems, sent int) {
+func (b *Bot) scanBrand(ctx context.Context, brand config.Brand) (found, newItems, sent int) {
+	if b.store.IsMuted(ctx, brand.Name) {
+		log.Printf("[%s] 🔇 Muted, skipping", brand.Name)
+		return
+	}
+
 	pMin, pMax := b.cfg.GetPriceRange(brand)
+	excludes := b.cfg.GetExcludeKeywords(brand)
+	filters := b.cfg.GetSearchFilters(brand)
 
-	for _, keyword := range brand.Keywords {
-		items, err := b.searchWithRetry(keyword, pMin, pMax, 3)
+	// A brand configured by ID alone is searched once without a keyword
+	keywords := brand.Keywords
+	if len(keywords) == 0 && len(brand.BrandIDs) > 0 {
+		keywords = []string{""}
+	}
+
+	for _, keyword := range keywords {
+		opts := mercari.SearchOptions{
+			Keyword:          keyword,
+			ExcludeKeywords:  excludes,
+			PriceMin:         pMin,
+			PriceMax:         b.searchCeiling(pMax),
+			CategoryIDs:      b.cfg.GetCategories(brand),
+			BrandIDs:         brand.BrandIDs,
+			ItemConditionIDs: filters.ConditionIDs,
+			SizeIDs:          filters.SizeIDs,
+			ColorIDs:         filters.ColorIDs,
+			ShippingPayerIDs: filters.ShippingPayerIDs,
+			Limit:            b.cfg.MaxDealsPerBrand * 2, // fetch more than needed, filter later
+		}
+		items, err := b.searchWithRetry(ctx, opts, 3)
 		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 			log.Printf("[%s] ❌ Search failed for '%s': %v", brand.Name, keyword, err)
 			continue
 		}
 
 		found += len(items)
+		b.recordBrands(ctx, items)
 
-		// Filter by age
-		var fresh []mercari.Item
-		for _, item := range items {
-			age := item.AgeMinutes()
-			if age <= float64(b.cfg.MaxAgeMinutes) {
-				fresh = append(fresh, item)
-			}
+		// Server-side exclusion is fuzzy; enforce it on item names too
+		items = mercari.ExcludeByKeywords(items, excludes)
+		items = mercari.FilterByBrandIDs(items, brand.BrandIDs)
+
+		newCount, sentCount := b.processItems(ctx, brand.Name, keyword, &brand, nil, items)
+		newItems += newCount
+		sent += sentCount
+	}
+
+	return
+}
+
+// scanSeller checks a watched seller for new listings.
+func (b *Bot) scanSeller(ctx context.Context, seller config.Seller) (found, newItems, sent int) {
+	if b.store.IsMuted(ctx, "👤 "+seller.Label) {
+		log.Printf("[👤 %s] 🔇 Muted, skipping", seller.Label)
+		return
+	}
+
+	opts := mercari.SearchOptions{
+		SellerIDs: []string{seller.ID},
+		PriceMax:  b.searchCeiling(seller.PriceMax),
+		Limit:     b.cfg.MaxDealsPerBrand * 2,
+	}
+	items, err := b.searchWithRetry(ctx, opts, 3)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[👤 %s] ❌ Search failed: %v", seller.Label, err)
 		}
+		return
+	}
+
+	found = len(items)
+	b.recordBrands(ctx, items)
 
-		// Dedup
-		var unseen []mercari.Item
-		for _, item := range fresh {
-			if !b.store.HasSeen(item.ID) {
+	newItems, sent = b.processItems(ctx, "👤 "+seller.Label, seller.ID, nil, &seller, items)
+	return
+}
+
+// keyRotation returns how long a DPoP key is used (0 = forever).
+func keyRotation(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.DPoPKeyRotationDays) * 24 * time.Hour
+}
+
+// rotateKeyIfDue replaces the scanner's DPoP key once it is older than
+// dpop_key_rotation_days, so a long-running bot rotates without a restart.
+func (b *Bot) rotateKeyIfDue() {
+	rotateAfter := keyRotation(b.cfg)
+	if rotateAfter <= 0 {
+		return
+	}
+	key, err := mercari.LoadOrCreateKey(filepath.Join(filepath.Dir(b.cfgPath), dpopKeyFile), rotateAfter)
+	if err != nil {
+		log.Printf("⚠️ DPoP key check failed: %v", err)
+		return
+	}
+	b.scanner.SetPrivateKey(key)
+}
+
+// scannedSources returns the source names of the brands and watched
+// sellers a cycle searched, leaving out muted ones.
+func (b *Bot) scannedSources(ctx context.Context, brands []config.Brand, sellers bool) []string {
+	var sources []string
+	for _, brand := range brands {
+		sources = append(sources, brand.Name)
+	}
+	if sellers {
+		for _, seller := range b.cfg.Sellers {
+			sources = append(sources, "👤 "+seller.Label)
+		}
+	}
+	scanned := sources[:0]
+	for _, source := range sources {
+		if !b.store.IsMuted(ctx, source) {
+			scanned = append(scanned, source)
+		}
+	}
+	return scanned
+}
+
+// processItems runs search results through the age filter, dedup, detail
+// enrichment and AI filter, then sends and records the survivors. Seen
+// items that reappear cheaper go through the same steps as price drops,
+// whatever their age.
+// source names the brand (or watched seller) for logs and the dedup store;
+// brand is set for brand searches (its chat routing applies) and watched
+// when the items come from a seller watch.
+func (b *Bot) processItems(ctx context.Context, source, query string, brand *config.Brand, watched *config.Seller, items []mercari.Item) (newItems, sent int) {
+	items = b.dropBlockedSellers(ctx, source, items)
+
+	// Filter by age and dedup, letting price drops of listings of any age
+	// through; previous maps their old prices
+	pMin, pMax := b.dealRange(brand, watched)
+	var unseen []mercari.Item
+	previous := make(map[string]int)
+	fresh := 0
+	for _, item := range items {
+		if prev, ok := b.priceDrop(ctx, item, pMin, pMax); ok {
+			if b.claim(item.ID) {
+				previous[item.ID] = prev
 				unseen = append(unseen, item)
 			}
+			continue
 		}
-		newItems += len(unseen)
-
-		if len(unseen) == 0 {
-			log.Printf("[%s] '%s': %d found, 0 new", brand.Name, keyword, len(items))
+		if item.AgeMinutes() > float64(b.cfg.MaxAgeMinutes) {
 			continue
 		}
-
-		// Limit deals per keyword
-		if len(unseen) > b.cfg.MaxDealsPerBrand {
-			unseen = unseen[:b.cfg.MaxDealsPerBrand]
+		fresh++
+		b.observePrice(ctx, source, item) // above the range too, to learn its price
+		if !inRange(item.Price, pMin, pMax) {
+			continue
 		}
+		if !b.store.HasSeen(ctx, item.ID) && b.claim(item.ID) {
+			unseen = append(unseen, item)
+		}
+	}
+	newItems = len(unseen)
 
-		// AI Filter
-		kept := b.filter.FilterItems(unseen)
+	if len(unseen) == 0 {
+		log.Printf("[%s] '%s': %d found, 0 new", source, query, len(items))
+		return
+	}
 
-		log.Printf("[%s] '%s': %d found → %d fresh → %d new → %d kept",
-			brand.Name, keyword, len(items), len(fresh), len(unseen), len(kept))
+	// Limit deals per keyword
+	if len(unseen) > b.cfg.MaxDealsPerBrand {
+		unseen = unseen[:b.cfg.MaxDealsPerBrand]
+	}
+
+	// Fetch description, photos, seller, condition and size
+	unseen = b.scanner.EnrichItems(ctx, unseen)
 
-		// Send notifications
+	// AI Filter
+	kept := b.filter.FilterItems(ctx, unseen)
+	if len(previous) > 0 {
+		keptIDs := make(map[string]bool)
 		for _, item := range kept {
-			deal := telegram.DealItem{
-				Name:      item.Name,
-				Price:     item.Price,
-				BrandName: brand.Name,
-				ImageURL:  firstImage(item.ImageURLs),
-				ItemURL:   item.ItemURL,
-				AgeMin:    item.AgeMinutes(),
+			keptIDs[item.ID] = true
+		}
+		for _, item := range unseen {
+			if _, ok := previous[item.ID]; ok && !keptIDs[item.ID] {
+				b.observePrice(ctx, source, item) // trashed: don't re-classify each cycle
 			}
+		}
+	}
 
-			if err := b.notifier.SendDeal(deal); err != nil {
-				log.Printf("[%s] ⚠️ Failed to send deal: %v", brand.Name, err)
-				continue
+	log.Printf("[%s] '%s': %d found → %d fresh → %d new → %d kept",
+		source, query, len(items), fresh, len(unseen), len(kept))
+
+	// Send notifications
+	for _, item := range kept {
+		if ctx.Err() != nil {
+			break
+		}
+		deal := notify.DealItem{
+			Name:          item.Name,
+			Price:         item.Price,
+			BrandName:     source,
+			ImageURL:      firstImage(item.ImageURLs),
+			ItemURL:       item.ItemURL,
+			AgeMin:        item.AgeMinutes(),
+			Description:   item.Description,
+			Condition:     item.Condition,
+			Size:          item.Size,
+			Seller:        item.Seller,
+			SellerStars:   item.SellerStars,
+			SellerRatings: item.SellerRatings,
+			PreviousPrice: previous[item.ID],
+			Item:          item,
+		}
+		if deal.PreviousPrice > 0 {
+			log.Printf("[%s] 💸 Price drop '%s': ¥%d → ¥%d", source, item.Name, deal.PreviousPrice, item.Price)
+		}
+		if brand != nil {
+			deal.ChatID, deal.ThreadID = brand.ChatID, brand.MessageThreadID
+		}
+		if watched != nil {
+			deal.BrandName = item.BrandName
+			deal.WatchedSeller = watched.Label
+		} else if b.cfg.Market.Enabled {
+			if median, pct, ok := b.marketScore(ctx, source, item); ok {
+				deal.MarketMedian, deal.BelowMarketPct = median, pct
+				if threshold := b.cfg.Market.MinBelowPct; threshold > 0 && pct < threshold {
+					log.Printf("[%s] 🙈 Suppressed '%s': %.0f%% below market (< %.0f%%)", source, item.Name, pct, threshold)
+					_ = b.store.MarkSeen(ctx, item.ID, source, item.Name, item.Price)
+					b.observePrice(ctx, source, item)
+					continue
+				}
 			}
+		}
 
-			// Mark as seen (even if send fails, to avoid spam)
-			_ = b.store.MarkSeen(item.ID, brand.Name, item.Name, item.Price)
+		// Pacing and rate limits are handled by each notifier
+		if err := b.notifier.SendDeal(ctx, deal); err != nil {
+			log.Printf("[%s] ⚠️ Failed to send deal: %v", source, err)
+			switch {
+			case isUndelivered(err):
+				// Telegram retries it next cycle; don't announce it again as new
+				b.deferDeal(context.WithoutCancel(ctx), source, deal, err)
+			case isRejected(err):
+				// Resending would fail the same way
+			case errors.Is(err, notify.ErrPartial):
+				// Another destination has it; resending would duplicate it there
+				sent++
+			default:
+				continue
+			}
+		} else {
 			sent++
-
-			// Rate limit: Telegram allows max 30 msg/sec, be conservative
-			time.Sleep(200 * time.Millisecond)
 		}
+
+		// The deal is out (or queued), so record it even if shutdown
+		// started meanwhile.
+		_ = b.store.MarkSeen(context.WithoutCancel(ctx), item.ID, source, item.Name, item.Price)
+		b.observePrice(context.WithoutCancel(ctx), source, item)
 	}
 
 	return
 }
 
+// resetClaims forgets the items claimed during the previous cycle.
+func (b *Bot) resetClaims() {
+	b.claimMu.Lock()
+	b.claimed = make(map[string]bool)
+	b.claimMu.Unlock()
+}
+
+// claim reserves an item for the calling worker. It returns false if another
+// brand already picked the item up in this cycle.
+func (b *Bot) claim(itemID string) bool {
+	b.claimMu.Lock()
+	defer b.claimMu.Unlock()
+	if b.claimed == nil {
+		b.claimed = make(map[string]bool)
+	}
+	if b.claimed[itemID] {
+		return false
+	}
+	b.claimed[itemID] = true
+	return true
+}
+
 // searchWithRetry performs the search with exponential backoff on failure.
-func (b *Bot) searchWithRetry(keyword string, priceMin, priceMax, maxRetries int) ([]mercari.Item, error) {
+func (b *Bot) searchWithRetry(ctx context.Context, opts mercari.SearchOptions, maxRetries int) ([]mercari.Item, error) {
 	var lastErr error
 
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		if attempt > 0 {
 			backoff := time.Duration(1<<uint(attempt-1)) * time.Second
 			jitter := time.Duration(rand.Intn(1000)) * time.Millisecond
-			log.Printf("[RETRY] Attempt %d/%d for '%s' after %v", attempt, maxRetries, keyword, backoff+jitter)
-			time.Sleep(backoff + jitter)
+			log.Printf("[RETRY] Attempt %d/%d for '%s' after %v", attempt, maxRetries, opts.Keyword, backoff+jitter)
+			if err := clock.Sleep(ctx, backoff+jitter); err != nil {
+				return nil, err
+			}
 		}
 
-		items, err := b.scanner.SearchWithFallback(
-			keyword, priceMin, priceMax,
-			b.cfg.DefaultCategories,
-			b.cfg.MaxDealsPerBrand*2, // fetch more than needed, filter later
-		)
+		items, err := b.scanner.SearchWithFallback(ctx, opts)
 		if err == nil {
 			return items, nil
 		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 
 		lastErr = err
-		log.Printf("[RETRY] '%s' attempt %d failed: %v", keyword, attempt, err)
+		log.Printf("[RETRY] '%s' attempt %d failed: %v", opts.Keyword, attempt, err)
 	}
 
 	return nil, fmt.Errorf("all %d retries failed: %w", maxRetries, lastErr)
 }
 
+// recordBrands remembers the Mercari brands seen in search results so
+// `autobot brands lookup` can find their IDs later.
+func (b *Bot) recordBrands(ctx context.Context, items []mercari.Item) {
+	recordBrands(ctx, b.store, items)
+}
+
+func recordBrands(ctx context.Context, st *store.DedupStore, items []mercari.Item) {
+	hits := make(map[int]int)
+	names := make(map[int]string)
+	for _, item := range items {
+		if item.BrandID > 0 {
+			hits[item.BrandID]++
+			names[item.BrandID] = item.BrandName
+		}
+	}
+	for id, n := range hits {
+		if err := st.RecordBrand(ctx, id, names[id], n); err != nil {
+			log.Printf("[STORE] ⚠️ %v", err)
+		}
+	}
+}
+
 // ---------- Helpers ----------
 
+// logConfig reports the loaded config and where each credential came from,
+// masked.
+func logConfig(path string, cfg *config.Config) {
+	log.Printf("✅ Config loaded from %s: %d brands, scan every %d min, price ¥%d-¥%d",
+		path, len(cfg.Brands), cfg.ScanIntervalMin, cfg.PriceMin, cfg.PriceMax)
+	for _, file := range cfg.Files[1:] {
+		log.Printf("   📄 included %s", file)
+	}
+	for _, src := range cfg.CredentialSources {
+		log.Printf("   🔑 %s", src)
+	}
+}
+
 func resolveConfigPath(path string) string {
 	if filepath.IsAbs(path) {
 		return path
@@ -0,0 +1,1074 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xuhoa/autobot/config"
+	"github.com/xuhoa/autobot/pkg/discord"
+	"github.com/xuhoa/autobot/pkg/mercari"
+	"github.com/xuhoa/autobot/pkg/mercari/mercaritest"
+	"github.com/xuhoa/autobot/pkg/notify"
+	"github.com/xuhoa/autobot/pkg/store"
+	"github.com/xuhoa/autobot/pkg/telegram"
+)
+
+// fakeTelegram records Bot API calls and answers them successfully,
+// unless told to fail the next sends.
+type fakeTelegram struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	calls   map[string][]map[string]interface{}
+	updates []string // raw update objects served by the next getUpdates
+	sent    int      // last message_id handed out
+	fail    []string // raw error responses for the next send calls
+}
+
+func newFakeTelegram() *fakeTelegram {
+	f := &fakeTelegram{calls: make(map[string][]map[string]interface{})}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		f.mu.Lock()
+		var fail string
+		if strings.HasPrefix(method, "send") && len(f.fail) > 0 {
+			fail, f.fail = f.fail[0], f.fail[1:]
+		} else {
+			f.calls[method] = append(f.calls[method], body) // successful calls only
+		}
+		updates := f.updates
+		f.updates = nil
+		f.sent++
+		messageID := f.sent
+		f.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if fail != "" {
+			_, _ = w.Write([]byte(fail))
+			return
+		}
+		if method == "getUpdates" {
+			_, _ = w.Write([]byte(`{"ok":true,"result":[` + strings.Join(updates, ",") + `]}`))
+			return
+		}
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d}}`, messageID)
+	}))
+	return f
+}
+
+// Queue makes the next getUpdates return the given raw update objects.
+func (f *fakeTelegram) Queue(updates ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updates = append(f.updates, updates...)
+}
+
+// FailNext makes the next send* calls answer with the given raw error
+// responses, one each.
+func (f *fakeTelegram) FailNext(responses ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail = append(f.fail, responses...)
+}
+
+// Deals returns the text-only deal messages (those with action buttons),
+// leaving out summaries and status replies.
+func (f *fakeTelegram) Deals() []map[string]interface{} {
+	var deals []map[string]interface{}
+	for _, m := range f.Calls("sendMessage") {
+		if m["reply_markup"] != nil {
+			deals = append(deals, m)
+		}
+	}
+	return deals
+}
+
+func (f *fakeTelegram) Calls(method string) []map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// newTestBot wires a Bot to a fake Mercari API and a fake Telegram.
+func newTestBot(t *testing.T, cfg *config.Config, items ...mercari.Item) (*Bot, *mercaritest.Server, *fakeTelegram) {
+	t.Helper()
+
+	mercariSrv := mercaritest.NewServer(items...)
+	t.Cleanup(mercariSrv.Close)
+	tg := newFakeTelegram()
+	t.Cleanup(tg.Close)
+
+	scanner, err := mercari.NewScanner(
+		mercari.WithBaseURL(mercariSrv.URL),
+		mercari.WithMaxPages(cfg.MaxPages),
+		mercari.WithMaxAge(time.Duration(cfg.MaxAgeMinutes)*time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("NewScanner: %v", err)
+	}
+
+	dedupStore, err := store.NewDedupStore(filepath.Join(t.TempDir(), "seen.db"))
+	if err != nil {
+		t.Fatalf("NewDedupStore: %v", err)
+	}
+	t.Cleanup(func() { dedupStore.Close() })
+
+	tgNotifier := telegram.NewNotifier("TOKEN", "42", telegram.WithAPIBase(tg.URL+"/bot"),
+		telegram.WithOnSent(recordAlert(dedupStore)), telegram.WithSendLimits(0, 0, 0))
+	bot := &Bot{
+		cfg:      cfg,
+		scanner:  scanner,
+		filter:   mercari.NewAIFilter("", cfg.HuggingFace.Model, false),
+		notifier: tgNotifier,
+		telegram: tgNotifier,
+		store:    dedupStore,
+	}
+	return bot, mercariSrv, tg
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Brands: []config.Brand{
+			{Name: "Undercover", Keywords: []string{"UNDERCOVER"}},
+		},
+		PriceMin:          3000,
+		PriceMax:          15000,
+		ScanIntervalMin:   10,
+		MaxAgeMinutes:     60,
+		MaxDealsPerBrand:  5,
+		MaxPages:          3,
+		ScanWorkers:       2,
+		DefaultCategories: []int{1, 2},
+	}
+}
+
+func TestScanCyclePipeline(t *testing.T) {
+	now := time.Now()
+	items := []mercari.Item{
+		{ID: "m001", Name: "UNDERCOVER scab jacket", Price: 12000, Created: now.Add(-5 * time.Minute),
+			ImageURLs: []string{"https://static.example/m001.jpg"}, SellerID: "111", Seller: "shop", Condition: "新品、未使用"},
+		{ID: "m002", Name: "UNDERCOVER tee", Price: 4000, Created: now.Add(-10 * time.Minute),
+			ImageURLs: []string{"https://static.example/m002.jpg"}, SellerID: "222"},
+		{ID: "m003", Name: "UNDERCOVER coat", Price: 40000, Created: now.Add(-1 * time.Minute)},     // over budget
+		{ID: "m004", Name: "UNDERCOVER old", Price: 5000, Created: now.Add(-3 * time.Hour)},         // too old
+		{ID: "m005", Name: "Yohji Yamamoto shirt", Price: 5000, Created: now.Add(-1 * time.Minute)}, // other brand
+	}
+	bot, mercariSrv, tg := newTestBot(t, testConfig(), items...)
+
+	bot.runScanCycle(context.Background())
+
+	photos := tg.Calls("sendPhoto")
+	if len(photos) != 2 {
+		t.Fatalf("sent %d deals, want 2", len(photos))
+	}
+	caption, _ := photos[0]["caption"].(string)
+	if !strings.Contains(caption, "scab jacket") || !strings.Contains(caption, "新品、未使用") {
+		t.Errorf("first deal caption missing details: %q", caption)
+	}
+	if errs := mercariSrv.DPoPErrors(); len(errs) > 0 {
+		t.Errorf("DPoP rejected: %v", errs)
+	}
+
+	// A second cycle must not resend anything
+	bot.runScanCycle(context.Background())
+	if n := len(tg.Calls("sendPhoto")); n != 2 {
+		t.Errorf("second cycle resent deals: %d photos total", n)
+	}
+	if bot.store.Count(context.Background()) != 2 {
+		t.Errorf("store tracks %d items, want 2", bot.store.Count(context.Background()))
+	}
+}
+
+func TestScanCycleCancelled(t *testing.T) {
+	items := []mercari.Item{
+		{ID: "m001", Name: "UNDERCOVER scab jacket", Price: 12000, Created: time.Now().Add(-5 * time.Minute)},
+	}
+	bot, _, tg := newTestBot(t, testConfig(), items...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bot.runScanCycle(ctx)
+
+	if n := len(tg.Calls("sendPhoto")) + len(tg.Calls("sendMessage")); n != 0 {
+		t.Errorf("cancelled cycle made %d Telegram calls, want 0", n)
+	}
+	if n := bot.store.Count(context.Background()); n != 0 {
+		t.Errorf("cancelled cycle marked %d items seen", n)
+	}
+}
+
+func TestScanBrandByID(t *testing.T) {
+	now := time.Now()
+	cfg := testConfig()
+	cfg.Brands = []config.Brand{{Name: "Y's", BrandIDs: []int{4321}}}
+	items := []mercari.Item{
+		{ID: "m101", Name: "Y's wool coat", Price: 9000, BrandID: 4321, BrandName: "ワイズ", Created: now.Add(-time.Minute)},
+		{ID: "m102", Name: "Y's style coat", Price: 9000, BrandID: 9999, BrandName: "ノーブランド", Created: now.Add(-time.Minute)},
+	}
+	bot, mercariSrv, tg := newTestBot(t, cfg, items...)
+
+	found, newItems, sent := bot.scanBrand(context.Background(), cfg.Brands[0])
+	if found != 1 || newItems != 1 || sent != 1 {
+		t.Errorf("found=%d new=%d sent=%d, want 1/1/1", found, newItems, sent)
+	}
+	searches := mercariSrv.Searches()
+	if len(searches) != 1 || searches[0].SearchCondition.Keyword != "" || len(searches[0].SearchCondition.BrandID) != 1 {
+		t.Errorf("unexpected search: %+v", searches)
+	}
+	if n := len(tg.Calls("sendMessage")); n != 1 {
+		t.Errorf("sent %d messages, want 1", n)
+	}
+
+	brands, err := bot.store.LookupBrands(context.Background(), "ワイズ", 5)
+	if err != nil || len(brands) != 1 || brands[0].ID != 4321 {
+		t.Errorf("LookupBrands = %+v, %v", brands, err)
+	}
+}
+
+func TestBrandsLookupIgnoresMaxAge(t *testing.T) {
+	// The scanner stops at max_age_minutes (60 here); a lookup must not
+	bot, _, _ := newTestBot(t, testConfig(),
+		mercari.Item{ID: "m401", Name: "Y's skirt", Price: 9000, Created: time.Now().Add(-5 * time.Hour),
+			BrandID: 3170, BrandName: "ワイズ"},
+	)
+
+	if err := lookupBrands(context.Background(), "Y's", bot.scanner, bot.store); err != nil {
+		t.Fatalf("lookupBrands: %v", err)
+	}
+	found, err := bot.store.LookupBrands(context.Background(), "ワイズ", 5)
+	if err != nil || len(found) != 1 || found[0].ID != 3170 {
+		t.Errorf("recorded brands = %+v, %v; want ワイズ (3170)", found, err)
+	}
+}
+
+func TestScanWatchedSeller(t *testing.T) {
+	now := time.Now()
+	cfg := testConfig()
+	seller := config.Seller{ID: "777", Label: "archive_jp", PriceMax: 20000}
+	cfg.Sellers = []config.Seller{seller}
+	items := []mercari.Item{
+		{ID: "m201", Name: "Number (N)ine jacket", Price: 18000, SellerID: "777", Created: now.Add(-2 * time.Minute),
+			ImageURLs: []string{"https://static.example/m201.jpg"}},
+		{ID: "m202", Name: "Number (N)ine coat", Price: 25000, SellerID: "777", Created: now.Add(-2 * time.Minute)},
+		{ID: "m203", Name: "Number (N)ine tee", Price: 3000, SellerID: "888", Created: now.Add(-2 * time.Minute)},
+	}
+	bot, mercariSrv, tg := newTestBot(t, cfg, items...)
+
+	_, newItems, sent := bot.scanSeller(context.Background(), seller)
+	if newItems != 1 || sent != 1 {
+		t.Fatalf("new=%d sent=%d, want 1/1", newItems, sent)
+	}
+	if ids := mercariSrv.Searches()[0].SearchCondition.SellerID; len(ids) != 1 || ids[0] != "777" {
+		t.Errorf("sellerId = %v", ids)
+	}
+	caption, _ := tg.Calls("sendPhoto")[0]["caption"].(string)
+	if !strings.Contains(caption, "Watched seller: archive_jp") {
+		t.Errorf("caption lacks watched seller: %q", caption)
+	}
+
+	// Seen items are not announced again
+	if _, _, sent := bot.scanSeller(context.Background(), seller); sent != 0 {
+		t.Errorf("resent %d seller deals", sent)
+	}
+}
+
+func TestMarketScore(t *testing.T) {
+	now := time.Now()
+	cfg := testConfig()
+	cfg.Market = config.MarketConfig{Enabled: true, RefreshHours: 24, MinSamples: 5, MinBelowPct: 20}
+
+	items := []mercari.Item{
+		{ID: "m301", Name: "UNDERCOVER knit", Price: 6000, Created: now.Add(-time.Minute), ImageURLs: []string{"https://static.example/a.jpg"}},
+		{ID: "m302", Name: "UNDERCOVER shirt", Price: 9500, Created: now.Add(-2 * time.Minute), ImageURLs: []string{"https://static.example/b.jpg"}},
+	}
+	// Sold history: median ¥10,000
+	for i, price := range []int{8000, 9000, 10000, 11000, 12000} {
+		items = append(items, mercari.Item{
+			ID: fmt.Sprintf("s%d", i), Name: "UNDERCOVER sold", Price: price, Status: "sold_out",
+			Created: now.Add(-30 * 24 * time.Hour), Updated: now.Add(-24 * time.Hour),
+		})
+	}
+	bot, _, tg := newTestBot(t, cfg, items...)
+
+	bot.runScanCycle(context.Background())
+
+	if bot.store.MarketRefreshedAt(context.Background(), "Undercover").IsZero() {
+		t.Fatal("market was not refreshed")
+	}
+	photos := tg.Calls("sendPhoto")
+	if len(photos) != 1 {
+		t.Fatalf("sent %d deals, want 1 (the ¥9,500 shirt is only 5%% below market)", len(photos))
+	}
+	caption, _ := photos[0]["caption"].(string)
+	if !strings.Contains(caption, "40% below market (median ¥10,000)") {
+		t.Errorf("caption lacks market score: %q", caption)
+	}
+}
+
+func TestMarketRefreshSkipsFailingBrand(t *testing.T) {
+	cfg := testConfig()
+	cfg.Market = config.MarketConfig{Enabled: true, RefreshHours: 24, MinSamples: 5}
+	cfg.Brands = []config.Brand{
+		{Name: "Broken", Keywords: []string{"BROKEN"}},
+		{Name: "Kapital", Keywords: []string{"KAPITAL"}},
+		{Name: "Undercover", Keywords: []string{"UNDERCOVER"}},
+		{Name: "Visvim", Keywords: []string{"VISVIM"}},
+	}
+	bot, srv, _ := newTestBot(t, cfg)
+	ctx := context.Background()
+
+	// The first brand's sold search fails; it must not use up a refresh slot
+	srv.FailNext(http.StatusBadRequest, 1)
+	bot.refreshMarket(ctx)
+	for _, name := range []string{"Kapital", "Undercover", "Visvim"} {
+		if bot.store.MarketRefreshedAt(ctx, name).IsZero() {
+			t.Errorf("%s not refreshed after another brand failed", name)
+		}
+	}
+	if !bot.store.MarketRefreshedAt(ctx, "Broken").IsZero() {
+		t.Error("failed brand marked refreshed")
+	}
+
+	// ... and is left out for a while instead of being retried every cycle
+	searches := len(srv.Searches())
+	bot.refreshMarket(ctx)
+	if n := len(srv.Searches()); n != searches {
+		t.Errorf("failed brand retried right away: %d searches", n-searches)
+	}
+}
+
+func TestConcurrentBrandsAnnounceOnce(t *testing.T) {
+	now := time.Now()
+	cfg := testConfig()
+	cfg.ScanWorkers = 4
+	cfg.Brands = []config.Brand{
+		{Name: "Undercover", Keywords: []string{"UNDERCOVER"}},
+		{Name: "John Undercover", Keywords: []string{"John UNDERCOVER"}},
+		{Name: "Undercover JP", Keywords: []string{"UNDERCOVER jacket"}},
+	}
+	var items []mercari.Item
+	for i := 0; i < 4; i++ {
+		items = append(items, mercari.Item{
+			ID: fmt.Sprintf("m40%d", i), Name: "John UNDERCOVER jacket", Price: 8000,
+			Created: now.Add(-time.Duration(i) * time.Minute), ImageURLs: []string{"https://static.example/x.jpg"},
+		})
+	}
+	bot, _, tg := newTestBot(t, cfg, items...)
+
+	bot.runScanCycle(context.Background())
+
+	if n := len(tg.Calls("sendPhoto")); n != 4 {
+		t.Errorf("sent %d deals, want each of the 4 items exactly once", n)
+	}
+}
+
+func TestBrandChatRouting(t *testing.T) {
+	now := time.Now()
+	cfg := testConfig()
+	cfg.Brands = []config.Brand{
+		{Name: "Undercover", Keywords: []string{"UNDERCOVER"}},
+		{Name: "Yohji", Keywords: []string{"Yohji"}, ChatID: "-100777", MessageThreadID: 12},
+	}
+	items := []mercari.Item{
+		{ID: "m501", Name: "UNDERCOVER tee", Price: 5000, Created: now.Add(-time.Minute), ImageURLs: []string{"https://static.example/a.jpg"}},
+		{ID: "m502", Name: "Yohji Yamamoto shirt", Price: 5000, Created: now.Add(-time.Minute), ImageURLs: []string{"https://static.example/b.jpg"}},
+	}
+	bot, _, tg := newTestBot(t, cfg, items...)
+
+	bot.runScanCycle(context.Background())
+
+	photos := tg.Calls("sendPhoto")
+	if len(photos) != 2 {
+		t.Fatalf("sent %d deals, want 2", len(photos))
+	}
+	for _, p := range photos {
+		caption, _ := p["caption"].(string)
+		chatID, _ := p["chat_id"].(string)
+		thread, _ := p["message_thread_id"].(float64)
+		switch {
+		case strings.Contains(caption, "Yohji") && (chatID != "-100777" || thread != 12):
+			t.Errorf("Yohji deal went to chat %s topic %v, want -100777 topic 12", chatID, thread)
+		case strings.Contains(caption, "UNDERCOVER") && (chatID != "42" || thread != 0):
+			t.Errorf("Undercover deal went to chat %s topic %v, want default chat 42", chatID, thread)
+		}
+	}
+}
+
+func TestDealButtons(t *testing.T) {
+	now := time.Now()
+	cfg := testConfig()
+	cfg.Brands = append(cfg.Brands, config.Brand{Name: "Yohji", Keywords: []string{"Yohji"}})
+	bot, srv, tg := newTestBot(t, cfg,
+		mercari.Item{ID: "m601", Name: "UNDERCOVER tee", Price: 5000, SellerID: "111", Created: now.Add(-time.Minute)},
+		mercari.Item{ID: "m602", Name: "Yohji Yamamoto shirt", Price: 5000, SellerID: "222", Created: now.Add(-time.Minute)},
+	)
+	bot.runScanCycle(context.Background())
+
+	msgs := tg.Deals()
+	if len(msgs) != 2 {
+		t.Fatalf("sent %d deals, want 2", len(msgs))
+	}
+	markup, _ := json.Marshal(msgs[0]["reply_markup"])
+	for _, want := range []string{"👀 Watch", "🔇 Mute brand 24h", "🚫 Block seller", "🗑 Not relevant"} {
+		if !strings.Contains(string(markup), want) {
+			t.Errorf("deal keyboard lacks %q: %s", want, markup)
+		}
+	}
+
+	// Block the Undercover seller and mute Yohji from the chat
+	tg.Queue(
+		`{"update_id":1,"callback_query":{"id":"q1","data":"b:m601:111","message":{"chat":{"id":42}}}}`,
+		`{"update_id":2,"callback_query":{"id":"q2","data":"m:m602","message":{"chat":{"id":42}}}}`,
+		`{"update_id":3,"callback_query":{"id":"q3","data":"n:m601","message":{"chat":{"id":-1}}}}`,
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bot.telegram.ListenForCommands(ctx, telegram.Handlers{Status: bot.getStatus, Action: bot.handleAction})
+		close(done)
+	}()
+	deadline := time.Now().Add(3 * time.Second)
+	for len(tg.Calls("answerCallbackQuery")) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	answers := tg.Calls("answerCallbackQuery")
+	if len(answers) != 3 || answers[2]["text"] != "Not allowed here" {
+		t.Fatalf("callback answers: %v", answers)
+	}
+
+	// New listings: one from the blocked seller, one from the muted brand
+	srv.SetItems(
+		mercari.Item{ID: "m603", Name: "UNDERCOVER hoodie", Price: 5000, SellerID: "111", Created: now},
+		mercari.Item{ID: "m604", Name: "Yohji Yamamoto coat", Price: 5000, SellerID: "333", Created: now},
+		mercari.Item{ID: "m605", Name: "UNDERCOVER cap", Price: 5000, SellerID: "444", Created: now},
+	)
+	bot.runScanCycle(context.Background())
+
+	msgs = tg.Deals()
+	if len(msgs) != 3 {
+		t.Fatalf("sent %d deals in total, want 3 (only the cap is new and allowed)", len(msgs))
+	}
+	if text, _ := msgs[2]["text"].(string); !strings.Contains(text, "UNDERCOVER cap") {
+		t.Errorf("third deal is %q, want the cap", text)
+	}
+}
+
+func TestBrandCommands(t *testing.T) {
+	bot, _, tg := newTestBot(t, testConfig())
+
+	tg.Queue(
+		`{"update_id":1,"message":{"chat":{"id":42},"text":"/addkw undercover “scab jacket” UNDERCOVER"}}`,
+		`{"update_id":2,"message":{"chat":{"id":42},"text":"/addbrand@AutoBot \"Kapital\" KAPITAL キャピタル"}}`,
+		`{"update_id":3,"message":{"chat":{"id":42},"text":"/setprice kapital 1,000 9000"}}`,
+		`{"update_id":4,"message":{"chat":{"id":42},"text":"/rmkw Kapital KAPITAL"}}`,
+		`{"update_id":5,"message":{"chat":{"id":42},"text":"/setprice Nope 1 2"}}`,
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bot.telegram.ListenForCommands(ctx, telegram.Handlers{Status: bot.getStatus, Commands: bot.commands()})
+		close(done)
+	}()
+	deadline := time.Now().Add(3 * time.Second)
+	for len(tg.Calls("sendMessage")) < 5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	replies := tg.Calls("sendMessage")
+	if len(replies) != 5 {
+		t.Fatalf("got %d replies, want 5", len(replies))
+	}
+	if text, _ := replies[4]["text"].(string); !strings.Contains(text, "no brand named") {
+		t.Errorf("unknown brand reply: %q", text)
+	}
+
+	brands := bot.brands()
+	if len(brands) != 2 {
+		t.Fatalf("have %d brands, want 2", len(brands))
+	}
+	if got := strings.Join(brands[0].Keywords, "|"); got != "UNDERCOVER|scab jacket" {
+		t.Errorf("Undercover keywords = %q", got)
+	}
+	if k := brands[1]; k.Name != "Kapital" || strings.Join(k.Keywords, "|") != "キャピタル" || k.PriceMin != 1000 || k.PriceMax != 9000 {
+		t.Errorf("Kapital = %+v", k)
+	}
+
+	// A restart with the original config picks the edits up from SQLite
+	cfg := testConfig()
+	if err := applyBrandOverlays(context.Background(), cfg, bot.store); err != nil {
+		t.Fatalf("applyBrandOverlays: %v", err)
+	}
+	if len(cfg.Brands) != 2 || len(cfg.Brands[0].Keywords) != 2 || cfg.Brands[1].PriceMax != 9000 {
+		t.Errorf("overlay not applied: %+v", cfg.Brands)
+	}
+
+	// Only the edited settings are overlaid: later file edits to the rest
+	// of the brand still apply
+	cfg = testConfig()
+	cfg.Brands[0].Categories = []int{3088}
+	cfg.Brands[0].ExcludeKeywords = []string{"ノベルティ"}
+	cfg.Brands[0].PriceMax = 20000
+	if err := applyBrandOverlays(context.Background(), cfg, bot.store); err != nil {
+		t.Fatalf("applyBrandOverlays: %v", err)
+	}
+	uc := cfg.Brands[0]
+	if len(uc.Keywords) != 2 || uc.PriceMax != 20000 || len(uc.Categories) != 1 || len(uc.ExcludeKeywords) != 1 {
+		t.Errorf("file edits lost under the overlay: %+v", uc)
+	}
+
+	// /resetbrand drops the edits, so the file entry applies again
+	if reply := bot.cmdResetBrand(context.Background(), []string{"undercover"}); !strings.Contains(reply, "Dropped") {
+		t.Fatalf("/resetbrand reply: %q", reply)
+	}
+	if reply := bot.cmdResetBrand(context.Background(), []string{"undercover"}); !strings.Contains(reply, "no Telegram edits") {
+		t.Errorf("second /resetbrand reply: %q", reply)
+	}
+	cfg = testConfig()
+	if err := applyBrandOverlays(context.Background(), cfg, bot.store); err != nil {
+		t.Fatalf("applyBrandOverlays: %v", err)
+	}
+	if got := strings.Join(cfg.Brands[0].Keywords, "|"); got != "UNDERCOVER" || len(cfg.Brands) != 2 {
+		t.Errorf("after /resetbrand: %+v", cfg.Brands)
+	}
+}
+
+func TestKeyRotationWithoutRestart(t *testing.T) {
+	cfg := testConfig()
+	cfg.DPoPKeyRotationDays = 30
+	bot, srv, _ := newTestBot(t, cfg, mercari.Item{ID: "m801", Name: "UNDERCOVER tee", Price: 5000, Created: time.Now()})
+	bot.cfgPath = filepath.Join(t.TempDir(), "config.json")
+
+	// A key created long ago, as if the bot had been running since
+	keyPath := filepath.Join(filepath.Dir(bot.cfgPath), dpopKeyFile)
+	if _, err := mercari.RotateKey(keyPath); err != nil {
+		t.Fatal(err)
+	}
+	old, _ := os.ReadFile(keyPath)
+	aged := regexp.MustCompile(`Created: \S+`).ReplaceAll(old, []byte("Created: 2020-01-01T00:00:00Z"))
+	if err := os.WriteFile(keyPath, aged, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	bot.runScanCycle(context.Background())
+	rotated, _ := os.ReadFile(keyPath)
+	if bytes.Equal(rotated, aged) {
+		t.Error("key past dpop_key_rotation_days not rotated by a scan cycle")
+	}
+	if errs := srv.DPoPErrors(); len(errs) > 0 {
+		t.Errorf("DPoP errors after rotation: %v", errs)
+	}
+
+	// A fresh key is kept
+	bot.runScanCycle(context.Background())
+	if again, _ := os.ReadFile(keyPath); !bytes.Equal(again, rotated) {
+		t.Error("fresh key rotated again")
+	}
+}
+
+func TestPauseAndOnDemandScan(t *testing.T) {
+	now := time.Now()
+	bot, _, tg := newTestBot(t, testConfig(),
+		mercari.Item{ID: "m701", Name: "UNDERCOVER tee", Price: 5000, Created: now.Add(-time.Minute)},
+	)
+	ctx := context.Background()
+
+	if reply := bot.cmdPause(ctx, []string{"2h"}); !strings.Contains(reply, "paused") {
+		t.Fatalf("/pause reply: %q", reply)
+	}
+	if !strings.Contains(bot.getStatus(), "Paused") {
+		t.Errorf("status does not show the pause: %q", bot.getStatus())
+	}
+
+	// The pause survives a restart
+	restarted := &Bot{cfg: bot.cfg, store: bot.store}
+	restarted.loadPause(ctx)
+	if paused, _ := restarted.paused(); !paused {
+		t.Error("pause was not restored from the database")
+	}
+
+	// While paused the loop skips its first scan but still honours /scan
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		bot.run(runCtx)
+		close(done)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	if n := len(tg.Deals()); n != 0 {
+		t.Fatalf("paused bot sent %d deals", n)
+	}
+	tg.Queue(`{"update_id":1,"message":{"chat":{"id":42},"text":"/scan undercover"}}`)
+	deadline := time.Now().Add(3 * time.Second)
+	for len(tg.Deals()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if n := len(tg.Deals()); n != 1 {
+		t.Errorf("/scan sent %d deals, want 1", n)
+	}
+
+	bot.cmdResume(ctx, nil)
+	if paused, _ := bot.paused(); paused {
+		t.Error("still paused after /resume")
+	}
+	if v, _ := bot.store.GetState(ctx, statePausedUntil); v != "" {
+		t.Errorf("stored pause not cleared: %q", v)
+	}
+}
+
+func TestRecheckAlerts(t *testing.T) {
+	now := time.Now()
+	cfg := testConfig()
+	cfg.Recheck = config.RecheckConfig{Enabled: true, IntervalMinutes: 15, WindowHours: 24}
+	tee := mercari.Item{ID: "m801", Name: "UNDERCOVER tee", Price: 9000, SellerID: "111", Created: now.Add(-time.Minute)}
+	jacket := mercari.Item{ID: "m802", Name: "UNDERCOVER jacket", Price: 12000, SellerID: "222", Created: now.Add(-time.Minute)}
+	shirt := mercari.Item{ID: "m803", Name: "UNDERCOVER shirt", Price: 8000, Created: now.Add(-time.Minute)}
+	bot, srv, tg := newTestBot(t, cfg, tee, jacket, shirt)
+	bot.runScanCycle(context.Background())
+	if n := len(tg.Deals()); n != 3 {
+		t.Fatalf("sent %d deals, want 3", n)
+	}
+
+	// The tee is bought, the jacket gets cheaper, the shirt is unchanged
+	tee.Status = mercari.ItemTrading
+	jacket.Price = 10000
+	srv.SetItems(tee, jacket, shirt)
+	bot.recheckAlerts(context.Background())
+
+	edits := tg.Calls("editMessageText")
+	if len(edits) != 2 {
+		t.Fatalf("edited %d alerts, want 2: %v", len(edits), edits)
+	}
+	var soldEdit, dropEdit map[string]interface{}
+	for _, e := range edits {
+		text, _ := e["text"].(string)
+		switch {
+		case strings.HasPrefix(text, "❌ <b>SOLD</b>") && strings.Contains(text, "UNDERCOVER tee"):
+			soldEdit = e
+		case strings.HasPrefix(text, "💸 <b>Price dropped ¥12,000 → ¥10,000</b>") && strings.Contains(text, "UNDERCOVER jacket"):
+			dropEdit = e
+		}
+	}
+	if soldEdit == nil || dropEdit == nil {
+		t.Fatalf("unexpected edits: %v", edits)
+	}
+	if soldEdit["reply_markup"] != nil {
+		t.Errorf("sold alert kept its buttons: %v", soldEdit["reply_markup"])
+	}
+	if dropEdit["reply_markup"] == nil {
+		t.Errorf("price-dropped alert lost its buttons")
+	}
+
+	// Sold alerts are no longer followed; the new price is the baseline
+	bot.recheckAlerts(context.Background())
+	if n := len(tg.Calls("editMessageText")); n != 2 {
+		t.Errorf("second re-check made %d edits in total, want 2", n)
+	}
+	alerts, err := bot.store.RecentAlerts(context.Background(), now.Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatalf("RecentAlerts: %v", err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("%d alerts still on sale, want 2", len(alerts))
+	}
+	for _, a := range alerts {
+		if a.ItemID == "m802" && a.Price != 10000 {
+			t.Errorf("jacket alert price = %d, want 10000", a.Price)
+		}
+	}
+
+	// The jacket sells, still showing its price drop; the shirt is taken
+	// off sale for now, which is neither sold nor edited
+	jacket.Status = mercari.ItemSoldOut
+	shirt.Status = mercari.ItemStopped
+	srv.SetItems(tee, jacket, shirt)
+	bot.recheckAlerts(context.Background())
+	edits = tg.Calls("editMessageText")
+	if len(edits) != 3 {
+		t.Fatalf("third re-check made %d edits in total, want 3", len(edits))
+	}
+	if text, _ := edits[2]["text"].(string); !strings.HasPrefix(text, "❌ <b>SOLD</b>\n💸 <b>Price dropped ¥12,000 → ¥10,000</b>") {
+		t.Errorf("sold jacket alert = %q, want the SOLD mark above its price drop", text)
+	}
+	alerts, _ = bot.store.RecentAlerts(context.Background(), now.Add(-time.Hour), 10)
+	if len(alerts) != 1 || alerts[0].ItemID != "m803" {
+		t.Errorf("alerts still followed = %+v, want the stopped shirt's", alerts)
+	}
+}
+
+func TestWatchedItemPriceDrop(t *testing.T) {
+	now := time.Now()
+	cfg := testConfig() // recheck disabled: watched items are followed anyway
+	jacket := mercari.Item{ID: "m811", Name: "UNDERCOVER jacket", Price: 12000, Created: now.Add(-time.Minute)}
+	tee := mercari.Item{ID: "m812", Name: "UNDERCOVER tee", Price: 9000, Created: now.Add(-time.Minute)}
+	bot, srv, tg := newTestBot(t, cfg, jacket, tee)
+	bot.runScanCycle(context.Background())
+
+	if reply, err := bot.handleAction(context.Background(), telegram.Action{Kind: telegram.ActionWatch, ItemID: "m811"}); err != nil || !strings.Contains(reply, "watch list") {
+		t.Fatalf("watch action = %q, %v", reply, err)
+	}
+
+	// Both get cheaper; only the watched jacket is followed and re-announced
+	jacket.Price, tee.Price = 10000, 7000
+	srv.SetItems(jacket, tee)
+	bot.recheckAlerts(context.Background())
+
+	if edits := tg.Calls("editMessageText"); len(edits) != 1 || !strings.Contains(edits[0]["text"].(string), "UNDERCOVER jacket") {
+		t.Fatalf("edits = %v, want the jacket alert only", edits)
+	}
+	deals := tg.Deals()
+	if len(deals) != 3 || !strings.Contains(deals[2]["text"].(string), "¥12,000</s> → ¥10,000") {
+		t.Fatalf("watched price drop not announced: %v", deals)
+	}
+
+	// The new price is the baseline: nothing more until it drops again
+	bot.recheckAlerts(context.Background())
+	if n := len(tg.Deals()); n != 3 {
+		t.Errorf("unchanged watched item announced again: %d deals", n)
+	}
+}
+
+func TestPriceDropAlerts(t *testing.T) {
+	now := time.Now()
+	cfg := testConfig()
+	cfg.PriceDrop = config.PriceDropConfig{Enabled: true, MinDropPct: 10, SearchCeilingPct: 100}
+	coat := mercari.Item{ID: "m901", Name: "UNDERCOVER coat", Price: 25000, Created: now.Add(-time.Minute)}
+	tee := mercari.Item{ID: "m902", Name: "UNDERCOVER tee", Price: 10000, Created: now.Add(-time.Minute)}
+	hat := mercari.Item{ID: "m903", Name: "UNDERCOVER cap", Price: 9000, Created: now.Add(-time.Minute)}
+	bot, srv, tg := newTestBot(t, cfg, coat, tee, hat)
+
+	bot.runScanCycle(context.Background())
+	if got := srv.Searches()[0].SearchCondition.PriceMax; got != 30000 {
+		t.Errorf("searched up to ¥%d, want the widened ceiling ¥30000", got)
+	}
+	if n := len(tg.Deals()); n != 2 {
+		t.Fatalf("first cycle sent %d deals, want 2 (coat is out of range)", n)
+	}
+
+	// Coat drops into range, tee is cut by 20%, hat only by 2%
+	coat.Price, tee.Price, hat.Price = 14000, 8000, 8800
+	srv.SetItems(coat, tee, hat)
+	bot.runScanCycle(context.Background())
+
+	deals := tg.Deals()[2:]
+	if len(deals) != 2 {
+		t.Fatalf("second cycle sent %d deals, want 2 price drops: %v", len(deals), deals)
+	}
+	for _, want := range []string{"Price drop: UNDERCOVER coat", "¥25,000</s> → ¥14,000", "Price drop: UNDERCOVER tee", "¥10,000</s> → ¥8,000"} {
+		found := false
+		for _, d := range deals {
+			if strings.Contains(d["text"].(string), want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("no price-drop alert contains %q", want)
+		}
+	}
+
+	// Unchanged prices are not announced again
+	bot.runScanCycle(context.Background())
+	if n := len(tg.Deals()); n != 4 {
+		t.Errorf("third cycle sent %d more deals, want 0", n-4)
+	}
+}
+
+func TestPriceDropAfterMaxAge(t *testing.T) {
+	now := time.Now()
+	cfg := testConfig()
+	cfg.PriceDrop = config.PriceDropConfig{Enabled: true, MinDropPct: 10, SearchCeilingPct: 100}
+	coat := mercari.Item{ID: "m911", Name: "UNDERCOVER coat", Price: 25000, Created: now.Add(-time.Minute)}
+	bot, srv, tg := newTestBot(t, cfg, coat)
+
+	bot.runScanCycle(context.Background())
+	if n := len(tg.Deals()); n != 0 {
+		t.Fatalf("first cycle sent %d deals, want 0 (coat is out of range)", n)
+	}
+
+	// Days later, long past max_age_minutes, the coat drops into range;
+	// searches stop before reaching it
+	coat.Created = now.Add(-72 * time.Hour)
+	coat.Price = 14000
+	srv.SetItems(coat)
+	bot.runScanCycle(context.Background())
+
+	deals := tg.Deals()
+	if len(deals) != 1 {
+		t.Fatalf("second cycle sent %d deals, want the price drop", len(deals))
+	}
+	if text := deals[0]["text"].(string); !strings.Contains(text, "¥25,000</s> → ¥14,000") {
+		t.Errorf("price-drop alert = %q", text)
+	}
+
+	// The new price is the baseline, and it is looked up at most hourly
+	bot.runScanCycle(context.Background())
+	if n := len(tg.Deals()); n != 1 {
+		t.Errorf("third cycle sent %d more deals, want 0", n-1)
+	}
+	gets := len(srv.ItemRequests())
+	bot.runScanCycle(context.Background())
+	if n := len(srv.ItemRequests()); n != gets {
+		t.Errorf("coat looked up again within the hour: %d item requests", n-gets)
+	}
+}
+
+func TestTelegramRateLimitAndRedelivery(t *testing.T) {
+	now := time.Now()
+	bot, srv, tg := newTestBot(t, testConfig(),
+		mercari.Item{ID: "m951", Name: "UNDERCOVER tee", Price: 5000, Created: now.Add(-time.Minute)},
+	)
+
+	// A 429 is waited out and the deal still goes out in the same cycle
+	tg.FailNext(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`)
+	start := time.Now()
+	bot.runScanCycle(context.Background())
+	if n := len(tg.Deals()); n != 1 {
+		t.Fatalf("sent %d deals after a 429, want 1", n)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("retried after %s, want at least the 1s retry_after", waited)
+	}
+
+	// A deal Telegram cannot take for now is not announced as new again
+	// but kept in the store and resent next cycle
+	ctx := context.Background()
+	srv.SetItems(mercari.Item{ID: "m952", Name: "UNDERCOVER cap", Price: 6000, Created: now.Add(-time.Minute)})
+	tg.FailNext(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 600","parameters":{"retry_after":600}}`)
+	bot.runScanCycle(ctx)
+	if n := len(tg.Deals()); n != 1 {
+		t.Fatalf("undelivered deal counted as sent: %d deals", n)
+	}
+	if pending, _ := bot.store.UndeliveredDeals(ctx, 10); len(pending) != 1 {
+		t.Fatalf("%d undelivered deals stored, want 1", len(pending))
+	}
+	bot.runScanCycle(ctx)
+	deals := tg.Deals()
+	if len(deals) != 2 || !strings.Contains(deals[1]["text"].(string), "UNDERCOVER cap") {
+		t.Fatalf("undelivered deal not resent exactly once: %v", deals)
+	}
+	if pending, _ := bot.store.UndeliveredDeals(ctx, 10); len(pending) != 0 {
+		t.Errorf("delivered deal still stored for resending")
+	}
+
+	// A deal Telegram refuses for good is not resent
+	srv.SetItems(mercari.Item{ID: "m953", Name: "UNDERCOVER belt", Price: 7000, Created: now.Add(-time.Minute)})
+	tg.FailNext(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`)
+	bot.runScanCycle(ctx)
+	bot.runScanCycle(ctx)
+	if n := len(tg.Deals()); n != 2 {
+		t.Errorf("rejected deal resent: %d deals", n)
+	}
+
+	// One that keeps failing is given up on and can be announced again
+	srv.SetItems()
+	tg.FailNext(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 600","parameters":{"retry_after":600}}`)
+	err := fmt.Errorf("%w: test", telegram.ErrUndelivered)
+	bot.deferDeal(ctx, "Undercover", notify.DealItem{Name: "UNDERCOVER bag", Item: mercari.Item{ID: "m954"}}, err)
+	_ = bot.store.MarkSeen(ctx, "m954", "Undercover", "UNDERCOVER bag", 8000)
+	for i := 1; i < maxDeliveryRounds; i++ { // earlier rounds failed too
+		_ = bot.store.RetryUndelivered(ctx, "m954", "test")
+	}
+	bot.runScanCycle(ctx)
+	if pending, _ := bot.store.UndeliveredDeals(ctx, 10); len(pending) != 0 {
+		t.Errorf("deal still queued after %d rounds", maxDeliveryRounds)
+	}
+	if bot.store.HasSeen(ctx, "m954") {
+		t.Error("deal given up on is still marked seen")
+	}
+}
+
+func TestPartialDeliveryMarksSeen(t *testing.T) {
+	bot, _, tg := newTestBot(t, testConfig(),
+		mercari.Item{ID: "m961", Name: "UNDERCOVER hoodie", Price: 7000, Created: time.Now().Add(-time.Minute)},
+	)
+	discordDown := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer discordDown.Close()
+	bot.notifier = notify.Multi{bot.telegram, discord.NewNotifier(discordDown.URL)}
+
+	// Telegram got it, so a Discord outage must not bring it back as new
+	bot.runScanCycle(context.Background())
+	bot.runScanCycle(context.Background())
+	if n := len(tg.Deals()); n != 1 {
+		t.Fatalf("sent %d Telegram deals while Discord was down, want 1", n)
+	}
+	if !bot.store.HasSeen(context.Background(), "m961") {
+		t.Error("partially delivered deal not marked seen")
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	bot, _, tg := newTestBot(t, testConfig())
+	bot.cfgPath = filepath.Join(t.TempDir(), "config.json")
+	write := func(s string) {
+		if err := os.WriteFile(bot.cfgPath, []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{
+		"telegram": {"bot_token": "TOKEN", "chat_id": "42"},
+		"scan_interval_minutes": 3,
+		"price_max": 20000,
+		"brands": [{"name": "Kapital", "keywords": ["KAPITAL"]}, {"name": "Undercover", "keywords": ["UNDERCOVER"]}]
+	}`)
+	if !bot.reloadConfig(context.Background()) {
+		t.Errorf("interval change not reported")
+	}
+	cfg := bot.config()
+	if len(bot.brands()) != 2 || cfg.PriceMax != 20000 || cfg.ScanIntervalMin != 3 {
+		t.Fatalf("reload not applied: %d brands, price_max %d, interval %d", len(bot.brands()), cfg.PriceMax, cfg.ScanIntervalMin)
+	}
+
+	// A brand edited over Telegram still picks up the file's other changes
+	if reply := bot.cmdAddKeyword(context.Background(), []string{"kapital", "キャピタル"}); !strings.Contains(reply, "Added keywords") {
+		t.Fatalf("/addkw reply: %q", reply)
+	}
+	write(`{
+		"telegram": {"bot_token": "TOKEN", "chat_id": "42"},
+		"scan_interval_minutes": 3,
+		"price_max": 20000,
+		"brands": [{"name": "Kapital", "keywords": ["KAPITAL"], "categories": [3088]}, {"name": "Undercover", "keywords": ["UNDERCOVER"]}]
+	}`)
+	bot.reloadConfig(context.Background())
+	cfg = bot.config()
+	if k := bot.brands()[0]; strings.Join(k.Keywords, "|") != "KAPITAL|キャピタル" || len(k.Categories) != 1 {
+		t.Fatalf("edited brand after reload = %+v", k)
+	}
+
+	// A brand moved to its own chat can send commands from there
+	write(`{
+		"telegram": {"bot_token": "TOKEN", "chat_id": "42"},
+		"scan_interval_minutes": 3,
+		"price_max": 20000,
+		"brands": [{"name": "Kapital", "keywords": ["KAPITAL"], "categories": [3088], "chat_id": "-100777"}, {"name": "Undercover", "keywords": ["UNDERCOVER"]}]
+	}`)
+	bot.reloadConfig(context.Background())
+	cfg = bot.config()
+	tg.Queue(`{"update_id":1,"message":{"chat":{"id":-100777},"text":"/check"}}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bot.telegram.ListenForCommands(ctx, telegram.Handlers{Status: bot.getStatus})
+		close(done)
+	}()
+	answered := func() bool {
+		for _, m := range tg.Calls("sendMessage") {
+			if fmt.Sprint(m["chat_id"]) == "-100777" {
+				return true
+			}
+		}
+		return false
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for !answered() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if !answered() {
+		t.Error("/check from a brand chat added by a reload went unanswered")
+	}
+
+	// A broken file is reported and the running config kept
+	write(`{"telegram": {"bot_token": "TOKEN", "chat_id": "42"}, "brands": [`)
+	if bot.reloadConfig(context.Background()) {
+		t.Errorf("rejected reload reported an interval change")
+	}
+	if bot.config() != cfg {
+		t.Errorf("config replaced by an invalid file")
+	}
+	msgs := tg.Calls("sendMessage")
+	if len(msgs) == 0 || !strings.Contains(msgs[len(msgs)-1]["text"].(string), "Config reload rejected") {
+		t.Errorf("invalid config not reported to Telegram: %v", msgs)
+	}
+}
+
+func TestBrandSchedule(t *testing.T) {
+	cfg := testConfig()
+	cfg.Brands = []config.Brand{
+		{Name: "Kapital", Keywords: []string{"KAPITAL"}},
+		{Name: "Hermes", Keywords: []string{"HERMES"}, Categories: []int{3088}, IntervalMinutes: 60},
+		{Name: "Undercover", Keywords: []string{"UNDERCOVER"}, IntervalMinutes: 1, Priority: 5},
+	}
+	cfg.Sellers = []config.Seller{{ID: "111", Label: "shop"}}
+	bot, mercariSrv, _ := newTestBot(t, cfg)
+
+	names := func(brands []config.Brand) string {
+		var out []string
+		for _, b := range brands {
+			out = append(out, b.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	// Everything is due before the first scan, hot brands first
+	t0 := time.Now()
+	if brands, sellers := bot.due(t0); names(brands) != "Undercover,Kapital,Hermes" || !sellers {
+		t.Fatalf("due before first scan = %s (sellers %v)", names(brands), sellers)
+	}
+	bot.runScanCycle(context.Background())
+
+	// Each brand searches its own categories
+	for _, s := range mercariSrv.Searches() {
+		if len(s.SearchCondition.SellerID) > 0 {
+			continue
+		}
+		want := []int{1, 2}
+		if s.SearchCondition.Keyword == "HERMES" {
+			want = []int{3088}
+		}
+		if !reflect.DeepEqual(s.SearchCondition.CategoryID, want) {
+			t.Errorf("%q searched categories %v, want %v", s.SearchCondition.Keyword, s.SearchCondition.CategoryID, want)
+		}
+	}
+
+	start := bot.lastScanned[brandTask("Kapital")]
+	if next := bot.nextDue(); !next.Equal(start.Add(time.Minute)) {
+		t.Errorf("next due %v after the cycle, want one minute after %v", next, start)
+	}
+	for _, c := range []struct {
+		after   time.Duration
+		brands  string
+		sellers bool
+	}{
+		{30 * time.Second, "", false},
+		{time.Minute, "Undercover", false},
+		{10 * time.Minute, "Undercover,Kapital", true},
+		{time.Hour, "Undercover,Kapital,Hermes", true},
+	} {
+		if brands, sellers := bot.due(start.Add(c.after)); names(brands) != c.brands || sellers != c.sellers {
+			t.Errorf("due after %v = %q (sellers %v), want %q (sellers %v)", c.after, names(brands), sellers, c.brands, c.sellers)
+		}
+	}
+
+	// A scheduled run scans only what is due
+	before := len(mercariSrv.Searches())
+	bot.lastScanned[brandTask("Undercover")] = start.Add(-time.Minute)
+	bot.runDue(context.Background())
+	if got := mercariSrv.Searches()[before:]; len(got) != 1 || got[0].SearchCondition.Keyword != "UNDERCOVER" {
+		t.Errorf("scheduled run searched %d times, want UNDERCOVER alone", len(got))
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xuhoa/autobot/config"
+	"github.com/xuhoa/autobot/pkg/mercari"
+)
+
+// marketRefreshPerCycle caps how many brands re-fetch sold listings in one
+// cycle, so a cold start does not add hundreds of requests to the first scan.
+const marketRefreshPerCycle = 3
+
+// marketRetryAfter is how long a brand whose refresh failed is left out,
+// so it does not take a refresh slot from the other brands every cycle.
+const marketRetryAfter = time.Hour
+
+// refreshMarket re-fetches sold listings for brands whose price history is
+// older than market.refresh_hours.
+func (b *Bot) refreshMarket(ctx context.Context) {
+	if !b.cfg.Market.Enabled {
+		return
+	}
+	if b.marketFailed == nil {
+		b.marketFailed = make(map[string]time.Time)
+	}
+
+	stale := time.Duration(b.cfg.Market.RefreshHours) * time.Hour
+	refreshed := 0
+	for _, brand := range b.brands() {
+		if refreshed >= marketRefreshPerCycle || ctx.Err() != nil {
+			break
+		}
+		if time.Since(b.store.MarketRefreshedAt(ctx, brand.Name)) < stale ||
+			time.Since(b.marketFailed[brand.Name]) < marketRetryAfter {
+			continue
+		}
+		if err := b.refreshBrandMarket(ctx, brand); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[MARKET] [%s] ❌ %v", brand.Name, err)
+				b.marketFailed[brand.Name] = time.Now()
+			}
+			continue
+		}
+		delete(b.marketFailed, brand.Name)
+		refreshed++
+	}
+}
+
+// refreshBrandMarket records the brand's recently sold listings. It fails
+// without marking the brand refreshed if any sold search fails.
+func (b *Bot) refreshBrandMarket(ctx context.Context, brand config.Brand) error {
+	excludes := b.cfg.GetExcludeKeywords(brand)
+
+	keywords := brand.Keywords
+	if len(keywords) == 0 && len(brand.BrandIDs) > 0 {
+		keywords = []string{""}
+	}
+
+	recorded := 0
+	for _, keyword := range keywords {
+		items, err := b.scanner.Search(ctx, mercari.SearchOptions{
+			Keyword:         keyword,
+			ExcludeKeywords: excludes,
+			CategoryIDs:     b.cfg.GetCategories(brand),
+			BrandIDs:        brand.BrandIDs,
+			Statuses:        []string{mercari.StatusSoldOut},
+			AnyAge:          true,
+			Limit:           120,
+		})
+		if err != nil {
+			return fmt.Errorf("sold search failed for '%s': %w", keyword, err)
+		}
+
+		items = mercari.ExcludeByKeywords(items, excludes)
+		items = mercari.FilterByBrandIDs(items, brand.BrandIDs)
+		for _, item := range items {
+			if err := b.store.RecordSold(ctx, item.ID, brand.Name, item.CategoryID, item.Price, item.Updated); err != nil {
+				log.Printf("[MARKET] ⚠️ %v", err)
+				continue
+			}
+			recorded++
+		}
+	}
+
+	_ = b.store.MarkMarketRefreshed(ctx, brand.Name)
+	log.Printf("[MARKET] [%s] Recorded %d sold listings", brand.Name, recorded)
+	return nil
+}
+
+// marketScore compares an item's price to the median sold price for its
+// brand and category (falling back to the whole brand when the category
+// has too few sales). belowPct is negative for items above the median.
+func (b *Bot) marketScore(ctx context.Context, brand string, item mercari.Item) (median int, belowPct float64, ok bool) {
+	minSamples := b.cfg.Market.MinSamples
+
+	stats, err := b.store.MarketStats(ctx, brand, item.CategoryID)
+	if err == nil && item.CategoryID > 0 && stats.Count < minSamples {
+		stats, err = b.store.MarketStats(ctx, brand, 0)
+	}
+	if err != nil {
+		log.Printf("[MARKET] ⚠️ %v", err)
+		return 0, 0, false
+	}
+	if stats.Count < minSamples || stats.Median <= 0 {
+		return 0, 0, false
+	}
+
+	belowPct = float64(stats.Median-item.Price) / float64(stats.Median) * 100
+	return stats.Median, belowPct, true
+}
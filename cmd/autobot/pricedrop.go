@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/xuhoa/autobot/config"
+	"github.com/xuhoa/autobot/pkg/mercari"
+)
+
+// priceRecheckInterval is how often a tracked listing that searches no
+// longer return is looked up for price drops.
+const priceRecheckInterval = time.Hour
+
+// searchCeiling returns the price ceiling to search with. With price-drop
+// alerts on it is raised by price_drop.search_ceiling_pct, so listings
+// just above the range are seen (and their prices recorded) before they
+// drop into it. 0 stays unlimited.
+func (b *Bot) searchCeiling(pMax int) int {
+	if !b.cfg.PriceDrop.Enabled || pMax <= 0 {
+		return pMax
+	}
+	return pMax + int(float64(pMax)*b.cfg.PriceDrop.SearchCeilingPct/100)
+}
+
+// dealRange returns the price range a listing must be in to be announced.
+func (b *Bot) dealRange(brand *config.Brand, watched *config.Seller) (pMin, pMax int) {
+	switch {
+	case brand != nil:
+		return b.cfg.GetPriceRange(*brand)
+	case watched != nil:
+		return 0, watched.PriceMax
+	}
+	return 0, 0
+}
+
+func inRange(price, pMin, pMax int) bool {
+	return price >= pMin && (pMax <= 0 || price <= pMax)
+}
+
+// priceDrop reports whether an item in the pMin–pMax range was seen before
+// at a higher price, either cut by at least price_drop.min_drop_pct or
+// coming down from above pMax, and returns that previous price.
+func (b *Bot) priceDrop(ctx context.Context, item mercari.Item, pMin, pMax int) (int, bool) {
+	if !b.cfg.PriceDrop.Enabled || !inRange(item.Price, pMin, pMax) {
+		return 0, false
+	}
+	prev, ok := b.store.LastPrice(ctx, item.ID)
+	if !ok || prev <= item.Price {
+		return 0, false
+	}
+	cutPct := float64(prev-item.Price) / float64(prev) * 100
+	intoRange := pMax > 0 && prev > pMax
+	return prev, cutPct >= b.cfg.PriceDrop.MinDropPct || intoRange
+}
+
+// observePrice records the price an item was seen at in the search results
+// of source, as the baseline for later price drops.
+func (b *Bot) observePrice(ctx context.Context, source string, item mercari.Item) {
+	if !b.cfg.PriceDrop.Enabled {
+		return
+	}
+	if err := b.store.RecordPrice(ctx, item.ID, source, item.Price); err != nil {
+		log.Printf("[STORE] ⚠️ %v", err)
+	}
+}
+
+// recheckPrices looks up tracked listings of the given sources that this
+// cycle's searches, which stop at max_age_minutes, no longer returned, and
+// runs those that got cheaper through processItems as price drops. Each
+// listing is looked up at most every priceRecheckInterval, recheckBatch
+// per cycle. Sold and removed listings are no longer tracked; stopped ones
+// are until they come back on sale.
+func (b *Bot) recheckPrices(ctx context.Context, cycleStart time.Time, sources []string) (sent int) {
+	if !b.cfg.PriceDrop.Enabled {
+		return 0
+	}
+	tracked, err := b.store.StalePrices(ctx, sources, cycleStart, time.Now().Add(-priceRecheckInterval), recheckBatch)
+	if err != nil {
+		log.Printf("[STORE] ⚠️ %v", err)
+		return 0
+	}
+	for _, t := range tracked {
+		if ctx.Err() != nil {
+			break
+		}
+		item, err := b.scanner.GetItem(ctx, t.ItemID)
+		if err != nil {
+			log.Printf("[%s] ⚠️ Price re-check of %s failed: %v", t.Source, t.ItemID, err)
+			continue
+		}
+		brand, watched := b.sourceOf(t.Source)
+		switch {
+		case item.Status == mercari.ItemSoldOut, item.Status == mercari.ItemTrading,
+			item.Status == mercari.ItemCancelled, brand == nil && watched == nil:
+			if err := b.store.ForgetPrice(ctx, t.ItemID); err != nil {
+				log.Printf("[STORE] ⚠️ %v", err)
+			}
+			continue
+		}
+		pMin, pMax := b.dealRange(brand, watched)
+		onSale := item.Status == mercari.ItemOnSale || item.Status == ""
+		if _, ok := b.priceDrop(ctx, *item, pMin, pMax); !ok || !onSale {
+			if err := b.store.CheckedPrice(ctx, t.ItemID, item.Price); err != nil {
+				log.Printf("[STORE] ⚠️ %v", err)
+			}
+			continue
+		}
+		// Announced drops record the new price as seen by a search, so
+		// the listing is looked up again once searches miss it again
+		query := "price re-check"
+		if watched != nil {
+			query = watched.ID
+		}
+		_, n := b.processItems(ctx, t.Source, query, brand, watched, []mercari.Item{*item})
+		sent += n
+	}
+	return sent
+}
+
+// sourceOf returns the configured brand or watched seller a source name
+// (as passed to processItems) stands for; both are nil if neither is
+// configured anymore.
+func (b *Bot) sourceOf(source string) (*config.Brand, *config.Seller) {
+	if label, ok := strings.CutPrefix(source, "👤 "); ok {
+		for _, seller := range b.cfg.Sellers {
+			if seller.Label == label {
+				return nil, &seller
+			}
+		}
+		return nil, nil
+	}
+	brands := b.brands()
+	if i := findBrand(brands, source); i >= 0 {
+		return &brands[i], nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/clock"
+	"github.com/xuhoa/autobot/pkg/mercari"
+	"github.com/xuhoa/autobot/pkg/notify"
+	"github.com/xuhoa/autobot/pkg/store"
+	"github.com/xuhoa/autobot/pkg/telegram"
+)
+
+// recheckBatch caps how many alerts are re-checked per round; each one
+// costs an item request against the shared rate limit.
+const recheckBatch = 30
+
+// recordAlert returns a telegram.WithOnSent callback that remembers each
+// delivered deal message for re-checking.
+func recordAlert(st *store.DedupStore) func(ctx context.Context, m telegram.SentDeal) {
+	return func(ctx context.Context, m telegram.SentDeal) {
+		err := st.RecordAlert(context.WithoutCancel(ctx), store.AlertMessage{
+			ItemID:    m.ItemID,
+			ChatID:    m.ChatID,
+			MessageID: m.MessageID,
+			Photo:     m.Photo,
+			Caption:   m.Caption,
+			SellerID:  m.SellerID,
+			Price:     m.Price,
+		})
+		if err != nil {
+			log.Printf("[RECHECK] ⚠️ %v", err)
+		}
+	}
+}
+
+// recheckLoop re-checks alerts every recheck.interval_minutes until ctx is
+// cancelled: watched items always, recent alerts while recheck is enabled.
+// It runs beside the scan loop and shares its rate limit.
+func (b *Bot) recheckLoop(ctx context.Context) {
+	for {
+		cfg := b.config()
+		if clock.Sleep(ctx, time.Duration(cfg.Recheck.IntervalMinutes)*time.Minute) != nil {
+			return
+		}
+		b.recheckAlerts(ctx)
+	}
+}
+
+// recheckAlerts looks up the alerts of watched items and, with recheck
+// enabled, those sent within recheck.window_hours, and edits their Telegram
+// message when the item has sold, was removed or got cheaper. A watched item that got
+// cheaper is also announced again, since an edit notifies no one.
+func (b *Bot) recheckAlerts(ctx context.Context) {
+	alerts, err := b.store.WatchedAlerts(ctx, recheckBatch)
+	if err != nil {
+		log.Printf("[RECHECK] ⚠️ %v", err)
+		return
+	}
+	watched := make(map[string]bool)
+	for _, a := range alerts {
+		watched[a.ItemID] = true
+	}
+	if cfg := b.config(); cfg.Recheck.Enabled {
+		since := time.Now().Add(-time.Duration(cfg.Recheck.WindowHours) * time.Hour)
+		recent, err := b.store.RecentAlerts(ctx, since, recheckBatch)
+		if err != nil {
+			log.Printf("[RECHECK] ⚠️ %v", err)
+		}
+		for _, a := range recent {
+			if !watched[a.ItemID] { // already listed
+				alerts = append(alerts, a)
+			}
+		}
+	}
+
+	sold, removed, dropped := 0, 0, 0
+	announced := make(map[string]bool) // watched items re-announced this round
+	for _, a := range alerts {
+		if ctx.Err() != nil {
+			break
+		}
+		item, err := b.scanner.GetItem(ctx, a.ItemID)
+		if err != nil {
+			log.Printf("[RECHECK] ⚠️ %s: %v", a.ItemID, err)
+			continue
+		}
+
+		status, price := store.AlertOnSale, a.Price
+		var note string // replaces a.Note above the caption
+		switch item.Status {
+		case mercari.ItemSoldOut, mercari.ItemTrading:
+			status = store.AlertSold
+			note = keepNote("❌ <b>SOLD</b>", a.Note)
+			sold++
+		case mercari.ItemCancelled:
+			status = store.AlertRemoved
+			note = keepNote("🚫 <b>Listing removed</b>", a.Note)
+			removed++
+		case mercari.ItemOnSale, "":
+			if item.Price > 0 && item.Price < a.Price {
+				price = item.Price
+				note = fmt.Sprintf("💸 <b>Price dropped ¥%s → ¥%s</b>",
+					notify.FormatPrice(a.Price), notify.FormatPrice(item.Price))
+				dropped++
+			}
+		case mercari.ItemStopped:
+			// Off sale for now; keep following it in case it comes back
+		default:
+			log.Printf("[RECHECK] ⚠️ %s: unknown status %q, left as is", a.ItemID, item.Status)
+		}
+
+		if note != "" {
+			if err := b.telegram.AnnotateDeal(ctx, sentDeal(a), note, status != store.AlertOnSale); err != nil {
+				log.Printf("[RECHECK] ⚠️ Could not edit alert for %s: %v", a.ItemID, err)
+				continue // try again next round
+			}
+		} else {
+			note = a.Note
+		}
+		if price < a.Price && watched[a.ItemID] && !announced[a.ItemID] {
+			announced[a.ItemID] = true
+			b.announceWatchedDrop(ctx, a, item)
+		}
+		if err := b.store.UpdateAlert(ctx, a.ChatID, a.MessageID, status, price, note); err != nil {
+			log.Printf("[RECHECK] ⚠️ %v", err)
+		}
+	}
+	if sold+removed+dropped > 0 {
+		log.Printf("[RECHECK] ✏️ Checked %d alerts: %d sold, %d removed, %d price drops", len(alerts), sold, removed, dropped)
+	}
+}
+
+// keepNote puts note above the one an alert already shows (e.g. its last
+// price drop), so marking it sold does not hide the price it sold at.
+func keepNote(note, last string) string {
+	if last == "" {
+		return note
+	}
+	return note + "\n" + last
+}
+
+// announceWatchedDrop sends a price-drop alert for a watched item to the
+// chat its first alert went to.
+func (b *Bot) announceWatchedDrop(ctx context.Context, a store.AlertMessage, item *mercari.Item) {
+	brand := item.BrandName
+	if seen, err := b.store.GetSeen(ctx, a.ItemID); err == nil {
+		brand = seen.Brand
+	}
+	deal := notify.DealItem{
+		Name:          item.Name,
+		Price:         item.Price,
+		BrandName:     brand,
+		ImageURL:      firstImage(item.ImageURLs),
+		ItemURL:       item.ItemURL,
+		AgeMin:        item.AgeMinutes(),
+		Description:   item.Description,
+		Condition:     item.Condition,
+		Size:          item.Size,
+		Seller:        item.Seller,
+		SellerStars:   item.SellerStars,
+		SellerRatings: item.SellerRatings,
+		PreviousPrice: a.Price,
+		ChatID:        a.ChatID,
+		Item:          *item,
+	}
+	if err := b.telegram.SendDeal(ctx, deal); err != nil {
+		log.Printf("[RECHECK] ⚠️ Could not announce watched price drop for %s: %v", a.ItemID, err)
+		return
+	}
+	log.Printf("[RECHECK] 👀 Watched '%s' dropped ¥%d → ¥%d", item.Name, a.Price, item.Price)
+}
+
+func sentDeal(a store.AlertMessage) telegram.SentDeal {
+	return telegram.SentDeal{
+		ChatID:    a.ChatID,
+		MessageID: a.MessageID,
+		Photo:     a.Photo,
+		Caption:   a.Caption,
+		ItemID:    a.ItemID,
+		SellerID:  a.SellerID,
+		Price:     a.Price,
+	}
+}
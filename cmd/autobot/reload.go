@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/xuhoa/autobot/config"
+	"github.com/xuhoa/autobot/pkg/clock"
+)
+
+// configPollInterval is how often the config file's modification time is
+// checked for hot reload.
+const configPollInterval = 5 * time.Second
+
+// config returns the current configuration. Goroutines other than the run
+// loop (commands, re-checks) must use it instead of reading b.cfg, which a
+// reload replaces.
+func (b *Bot) config() *config.Config {
+	b.cfgMu.RLock()
+	defer b.cfgMu.RUnlock()
+	return b.cfg
+}
+
+// watchConfig asks the run loop to reload whenever the modification time
+// or size of the config file, or of a brand file it includes, changes.
+func (b *Bot) watchConfig(ctx context.Context) {
+	last, _ := b.configStamp()
+	for clock.Sleep(ctx, configPollInterval) == nil {
+		stamp, err := b.configStamp()
+		if err != nil || stamp == last {
+			continue // a missing file may be mid-save; check again later
+		}
+		last = stamp
+		b.requestReload()
+	}
+}
+
+// configStamp sums up the modification times and sizes of the files the
+// running config was read from. A new file matching an include pattern is
+// only picked up by a reload (edit the main file or send SIGHUP).
+func (b *Bot) configStamp() (string, error) {
+	files := b.config().Files
+	if len(files) == 0 {
+		files = []string{b.cfgPath}
+	}
+	var sb strings.Builder
+	for _, path := range files {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "%s %d %d\n", path, fi.ModTime().UnixNano(), fi.Size())
+	}
+	return sb.String(), nil
+}
+
+// requestReload queues a reload for the run loop (file change or SIGHUP).
+func (b *Bot) requestReload() {
+	select {
+	case b.reloadReqs <- struct{}{}:
+	default: // one is already pending
+	}
+}
+
+// reloadConfig re-reads and validates the config file and swaps it in. It
+// runs on the run loop, so it never lands in the middle of a cycle. An
+// invalid file is reported and the running config kept. It returns
+// whether the scan interval changed.
+func (b *Bot) reloadConfig(ctx context.Context) (intervalChanged bool) {
+	oldInterval := b.config().ScanIntervalMin
+	cfg, err := config.LoadConfig(b.cfgPath)
+	if err == nil {
+		err = b.swapConfig(ctx, cfg)
+	}
+	if err != nil {
+		log.Printf("[CONFIG] ❌ Reload rejected, keeping the running config: %v", err)
+		_ = b.notifier.SendError(ctx, fmt.Sprintf("Config reload rejected, keeping the running config:\n%v", err))
+		return false
+	}
+
+	log.Printf("[CONFIG] 🔄 Reloaded %d file(s): %d brands, scan every %d min, price ¥%d-¥%d",
+		len(cfg.Files), len(cfg.Brands), cfg.ScanIntervalMin, cfg.PriceMin, cfg.PriceMax)
+	return cfg.ScanIntervalMin != oldInterval
+}
+
+// swapConfig replaces the running config with cfg plus the brand edits
+// made over Telegram. Those only override keywords and prices, so every
+// other brand change in the file applies; an edit hiding a file change is
+// logged by applyBrandOverlays. Overlays are applied under the lock, so an
+// edit made meanwhile is not lost.
+func (b *Bot) swapConfig(ctx context.Context, cfg *config.Config) error {
+	b.cfgMu.Lock()
+	defer b.cfgMu.Unlock()
+	if err := applyBrandOverlays(ctx, cfg, b.store); err != nil {
+		return err
+	}
+	for _, field := range restartOnlyChanges(b.cfg, cfg) {
+		log.Printf("[CONFIG] ⚠️ %s changed; restart to apply it", field)
+	}
+	b.cfg = cfg
+	if b.telegram != nil {
+		// Brands may have gained or moved chats
+		b.telegram.SetCommandChats(cfg.CommandChats()...)
+	}
+	return nil
+}
+
+// restartOnlyChanges lists changed settings that are baked into components
+// built at startup (notifiers, scanner, AI filter) and so are not applied
+// by a reload.
+func restartOnlyChanges(old, cfg *config.Config) []string {
+	var changed []string
+	check := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			changed = append(changed, name)
+		}
+	}
+	check("telegram", old.Telegram, cfg.Telegram)
+	check("discord", old.Discord, cfg.Discord)
+	check("notifiers", old.Notifiers, cfg.Notifiers)
+	check("webhooks", old.Webhooks, cfg.Webhooks)
+	check("huggingface", old.HuggingFace, cfg.HuggingFace)
+	check("enable_ai_filter", old.EnableAIFilter, cfg.EnableAIFilter)
+	check("max_age_minutes", old.MaxAgeMinutes, cfg.MaxAgeMinutes)
+	check("max_pages", old.MaxPages, cfg.MaxPages)
+	check("requests_per_second", old.RequestsPerSecond, cfg.RequestsPerSecond)
+	check("request_burst", old.RequestBurst, cfg.RequestBurst)
+	return changed
+}
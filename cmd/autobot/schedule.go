@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xuhoa/autobot/config"
+)
+
+// The run loop scans each brand on its own interval (interval_minutes,
+// default scan_interval_minutes) and the watched sellers together on the
+// global one. A brand or seller list never scanned yet, e.g. one added by
+// a reload or /addbrand, is due at once.
+
+// sellersTask is the schedule key shared by all watched sellers.
+const sellersTask = "sellers"
+
+func brandTask(name string) string {
+	return "brand:" + strings.ToLower(strings.TrimSpace(name))
+}
+
+// markScanned records that the brands (and the sellers, if set) were
+// scanned at t. Only the run loop's goroutine may call it.
+func (b *Bot) markScanned(t time.Time, brands []config.Brand, sellers bool) {
+	if b.lastScanned == nil {
+		b.lastScanned = make(map[string]time.Time)
+	}
+	for _, brand := range brands {
+		b.lastScanned[brandTask(brand.Name)] = t
+	}
+	if sellers {
+		b.lastScanned[sellersTask] = t
+	}
+}
+
+// dueAt returns when a task is next due; the zero time if never scanned.
+func (b *Bot) dueAt(task string, every time.Duration) time.Time {
+	last, ok := b.lastScanned[task]
+	if !ok {
+		return time.Time{}
+	}
+	return last.Add(every)
+}
+
+// due returns the brands due at now, highest priority first, and whether
+// the sellers are due.
+func (b *Bot) due(now time.Time) (brands []config.Brand, sellers bool) {
+	cfg := b.config()
+	for _, brand := range b.brands() {
+		if !now.Before(b.dueAt(brandTask(brand.Name), cfg.GetScanInterval(brand))) {
+			brands = append(brands, brand)
+		}
+	}
+	sellers = len(cfg.Sellers) > 0 &&
+		!now.Before(b.dueAt(sellersTask, time.Duration(cfg.ScanIntervalMin)*time.Minute))
+	return byPriority(brands), sellers
+}
+
+// nextDue returns when the next brand or seller scan is due.
+func (b *Bot) nextDue() time.Time {
+	cfg := b.config()
+	global := time.Duration(cfg.ScanIntervalMin) * time.Minute
+	next := time.Now().Add(global) // nothing to scan: check again later
+	for _, brand := range b.brands() {
+		if at := b.dueAt(brandTask(brand.Name), cfg.GetScanInterval(brand)); at.Before(next) {
+			next = at
+		}
+	}
+	if len(cfg.Sellers) > 0 {
+		if at := b.dueAt(sellersTask, global); at.Before(next) {
+			next = at
+		}
+	}
+	return next
+}
+
+// runDue scans the brands and sellers that are due. While paused they are
+// skipped until their next turn.
+func (b *Bot) runDue(ctx context.Context) {
+	now := time.Now()
+	brands, sellers := b.due(now)
+	if len(brands) == 0 && !sellers {
+		return
+	}
+	if paused, until := b.paused(); paused {
+		log.Printf("⏸ Paused %s, skipping scan", pauseLabel(until))
+		b.markScanned(now, brands, sellers)
+		return
+	}
+	b.scanCycle(ctx, brands, sellers)
+}
+
+// byPriority returns brands sorted by descending priority, keeping the
+// config order among equals. The input is not modified.
+func byPriority(brands []config.Brand) []config.Brand {
+	sorted := append([]config.Brand(nil), brands...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+	return sorted
+}
+
+// resetTimer re-arms t to fire after d, discarding a tick that fired
+// while the run loop was busy.
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
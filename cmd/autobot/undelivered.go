@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/xuhoa/autobot/pkg/notify"
+	"github.com/xuhoa/autobot/pkg/telegram"
+)
+
+// maxDeliveryRounds is how many cycles an undelivered Telegram deal is
+// retried before it is dropped.
+const maxDeliveryRounds = 3
+
+// resendBatch caps how many undelivered deals are resent per cycle.
+const resendBatch = 50
+
+// deferDeal stores a deal whose Telegram delivery failed for now, to be
+// resent next cycle (after a restart too). Other notifiers keep their own
+// retry handling.
+func (b *Bot) deferDeal(ctx context.Context, source string, deal notify.DealItem, sendErr error) {
+	body, err := json.Marshal(deal)
+	if err == nil {
+		err = b.store.RecordUndelivered(ctx, deal.Item.ID, source, body, sendErr.Error())
+	}
+	if err != nil {
+		log.Printf("[%s] ⚠️ Could not queue '%s' for resending: %v", source, deal.Name, err)
+	}
+}
+
+// resendUndelivered retries the deals Telegram failed to take in earlier
+// cycles and returns how many went out. A deal given up on is no longer
+// seen, so a search that still finds it announces it again.
+func (b *Bot) resendUndelivered(ctx context.Context) (sent int) {
+	if b.telegram == nil {
+		return 0
+	}
+	pending, err := b.store.UndeliveredDeals(ctx, resendBatch)
+	if err != nil {
+		log.Printf("[STORE] ⚠️ %v", err)
+		return 0
+	}
+	if len(pending) == 0 {
+		return 0
+	}
+
+	for _, u := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		var deal notify.DealItem
+		if err := json.Unmarshal(u.Deal, &deal); err != nil {
+			log.Printf("[%s] 🗑 Dropping unreadable undelivered deal %s: %v", u.Source, u.ItemID, err)
+			b.dropUndelivered(ctx, u.ItemID, false)
+			continue
+		}
+		err := b.telegram.SendDeal(ctx, deal)
+		switch {
+		case err == nil:
+			sent++
+			b.dropUndelivered(ctx, u.ItemID, false)
+		case isRejected(err):
+			log.Printf("[%s] 🗑 Telegram rejected '%s': %v", u.Source, deal.Name, err)
+			b.dropUndelivered(ctx, u.ItemID, false)
+		case u.Rounds+1 > maxDeliveryRounds:
+			log.Printf("[%s] 🗑 Giving up on '%s' after %d rounds: %v", u.Source, deal.Name, maxDeliveryRounds, err)
+			b.dropUndelivered(ctx, u.ItemID, true)
+		default:
+			if err := b.store.RetryUndelivered(context.WithoutCancel(ctx), u.ItemID, err.Error()); err != nil {
+				log.Printf("[STORE] ⚠️ %v", err)
+			}
+		}
+	}
+
+	log.Printf("🔁 Resent %d of %d undelivered deal(s)", sent, len(pending))
+	return sent
+}
+
+// dropUndelivered stops resending a deal, and with unsee forgets it was
+// seen.
+func (b *Bot) dropUndelivered(ctx context.Context, itemID string, unsee bool) {
+	ctx = context.WithoutCancel(ctx)
+	if err := b.store.DeleteUndelivered(ctx, itemID); err != nil {
+		log.Printf("[STORE] ⚠️ %v", err)
+	}
+	if !unsee {
+		return
+	}
+	if err := b.store.Unsee(ctx, itemID); err != nil {
+		log.Printf("[STORE] ⚠️ %v", err)
+	}
+}
+
+// isUndelivered reports whether a SendDeal error means Telegram never got
+// the deal but may take it later.
+func isUndelivered(err error) bool {
+	return errors.Is(err, telegram.ErrUndelivered)
+}
+
+// isRejected reports whether a SendDeal error means Telegram refused the
+// deal for good.
+func isRejected(err error) bool {
+	return errors.Is(err, telegram.ErrRejected)
+}
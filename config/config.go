@@ -2,39 +2,118 @@
 package config
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/mercari"
 )
 
 // Config is the root configuration struct loaded from config.json.
 type Config struct {
-	Telegram  TelegramConfig `json:"telegram"`
-	HuggingFace HFConfig    `json:"huggingface"`
-	Brands    []Brand        `json:"brands"`
+	Telegram    TelegramConfig  `json:"telegram"`
+	Discord     DiscordConfig   `json:"discord"`
+	Notifiers   []string        `json:"notifiers"` // alert destinations: telegram, discord, webhook (default: telegram)
+	Webhooks    []WebhookConfig `json:"webhooks"`  // endpoints for the "webhook" notifier
+	HuggingFace HFConfig        `json:"huggingface"`
+	Brands      []Brand         `json:"brands"`
+	Sellers     []Seller        `json:"sellers"` // watched sellers, scanned every cycle
+	Include     []string        `json:"include"` // brand list files appended to Brands, as globs relative to this file (e.g. "brands/*.yaml")
 
 	// Search parameters
-	PriceMin          int    `json:"price_min"`
-	PriceMax          int    `json:"price_max"`
-	ScanIntervalMin   int    `json:"scan_interval_minutes"`
-	MaxAgeMinutes     int    `json:"max_age_minutes"`
-	MaxDealsPerBrand  int    `json:"max_deals_per_keyword"`
-	DefaultCategories []int  `json:"default_categories"`
+	PriceMin          int      `json:"price_min"`
+	PriceMax          int      `json:"price_max"`
+	ScanIntervalMin   int      `json:"scan_interval_minutes"`
+	MaxAgeMinutes     int      `json:"max_age_minutes"`
+	MaxDealsPerBrand  int      `json:"max_deals_per_keyword"`
+	MaxPages          int      `json:"max_pages"` // result pages followed per keyword search
+	DefaultCategories []int    `json:"default_categories"`
+	ExcludeKeywords   []string `json:"exclude_keywords"` // applied to every brand
+
+	// Concurrency and politeness towards Mercari
+	ScanWorkers       int     `json:"scan_workers"`        // brands scanned in parallel
+	RequestsPerSecond float64 `json:"requests_per_second"` // shared across all workers
+	RequestBurst      int     `json:"request_burst"`
+
+	// Listing filters, overridable per brand (see Brand)
+	Conditions []string `json:"conditions"` // new, like_new, good, fair, poor, bad
+	Sizes      []string `json:"sizes"`      // clothing sizes: XS, S, M, L, XL, FREE...
+	SizeIDs    []int    `json:"size_ids"`   // raw Mercari size IDs (e.g. shoes)
+	ColorIDs   []int    `json:"color_ids"`  // raw Mercari color IDs
+	Shipping   string   `json:"shipping"`   // seller, buyer or any
 
 	// AI Filter
 	EnableAIFilter bool `json:"enable_ai_filter"`
+
+	// Market value scoring from sold listings
+	Market MarketConfig `json:"market"`
+
+	// Alerts for known listings that got cheaper
+	PriceDrop PriceDropConfig `json:"price_drop"`
+
+	// Re-checking sent alerts for sales and price drops (Telegram only)
+	Recheck RecheckConfig `json:"recheck"`
+
+	// DPoP key rotation period in days (0 = keep the stored key forever)
+	DPoPKeyRotationDays int `json:"dpop_key_rotation_days"`
+
+	// Where each credential came from (config, env, file); see secrets.go
+	CredentialSources []CredentialSource `json:"-"`
+
+	// Every file the config was read from, the main one first
+	Files []string `json:"-"`
 }
 
 // TelegramConfig holds Telegram Bot credentials.
 type TelegramConfig struct {
-	BotToken string `json:"bot_token"`
-	ChatID   string `json:"chat_id"`
+	BotToken     string `json:"bot_token"`
+	ChatID       string `json:"chat_id"`
+	BotTokenFile string `json:"bot_token_file,omitempty"` // read bot_token from this file
+	ChatIDFile   string `json:"chat_id_file,omitempty"`   // read chat_id from this file
+}
+
+// DiscordConfig holds the Discord incoming webhook alerts are posted to.
+type DiscordConfig struct {
+	WebhookURL string `json:"webhook_url"`
+}
+
+// WebhookConfig is an HTTP endpoint that receives every deal as signed JSON.
+type WebhookConfig struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"` // HMAC-SHA256 key for X-AutoBot-Signature
+}
+
+// MarketConfig controls sold-price history and "% below market" scoring.
+type MarketConfig struct {
+	Enabled      bool    `json:"enabled"`
+	RefreshHours int     `json:"refresh_hours"` // how often each brand's sold listings are re-fetched (default 24)
+	MinSamples   int     `json:"min_samples"`   // sold listings needed before a score is shown (default 5)
+	MinBelowPct  float64 `json:"min_below_pct"` // suppress deals scoring below this (0 = never suppress)
+}
+
+// PriceDropConfig controls alerts for listings seen before at a higher
+// price: either cut by at least MinDropPct or dropped into the price range.
+type PriceDropConfig struct {
+	Enabled          bool    `json:"enabled"`
+	MinDropPct       float64 `json:"min_drop_pct"`       // smallest cut worth an alert for in-range items (default 10)
+	SearchCeilingPct float64 `json:"search_ceiling_pct"` // search this far above price_max to learn prices before they drop (default 50)
+}
+
+// RecheckConfig controls editing sent Telegram alerts when the item sells
+// or its price drops.
+type RecheckConfig struct {
+	Enabled         bool `json:"enabled"`
+	IntervalMinutes int  `json:"interval_minutes"` // how often recent alerts are re-checked (default 15)
+	WindowHours     int  `json:"window_hours"`     // how long after sending an alert is followed (default 24)
 }
 
 // HFConfig holds HuggingFace Inference API credentials.
 type HFConfig struct {
-	APIKey string `json:"api_key"` // free tier key from huggingface.co/settings/tokens
-	Model  string `json:"model"`   // default: openai/clip-vit-large-patch14
+	APIKey     string `json:"api_key"`                // free tier key from huggingface.co/settings/tokens
+	Model      string `json:"model"`                  // default: openai/clip-vit-large-patch14
+	APIKeyFile string `json:"api_key_file,omitempty"` // read api_key from this file
 }
 
 // Brand represents a brand to search with multiple keywords.
@@ -43,55 +122,171 @@ type Brand struct {
 	Keywords []string `json:"keywords"`
 	PriceMin int      `json:"price_min,omitempty"` // override global if set
 	PriceMax int      `json:"price_max,omitempty"` // override global if set
+
+	// Telegram destination for this brand's deals (default: telegram.chat_id).
+	// MessageThreadID selects a forum topic within the chat.
+	ChatID          string `json:"chat_id,omitempty"`
+	MessageThreadID int    `json:"message_thread_id,omitempty"`
+
+	// Mercari brand IDs (see `autobot brands lookup`). When set, searches are
+	// restricted to these brands and Keywords become optional.
+	BrandIDs []int `json:"brand_ids,omitempty"`
+
+	// Mercari category IDs searched for this brand (default: default_categories)
+	Categories []int `json:"categories,omitempty"`
+
+	// Scheduling: how often the brand is scanned (default:
+	// scan_interval_minutes), and its rank when several brands are due at
+	// once. Higher priorities are scanned first and so claim a listing that
+	// matches several brands.
+	IntervalMinutes int `json:"interval_minutes,omitempty"`
+	Priority        int `json:"priority,omitempty"`
+
+	// Words that disqualify a listing, e.g. "CDG PLAY" for CDG searches
+	ExcludeKeywords []string `json:"exclude_keywords,omitempty"`
+
+	// Listing filters; each one replaces the global value when set
+	Conditions []string `json:"conditions,omitempty"`
+	Sizes      []string `json:"sizes,omitempty"`
+	SizeIDs    []int    `json:"size_ids,omitempty"`
+	ColorIDs   []int    `json:"color_ids,omitempty"`
+	Shipping   string   `json:"shipping,omitempty"`
+
+	// Included file the brand came from and its position there, for
+	// problem reports (empty for brands in the main file)
+	file  string
+	index int
+}
+
+// Seller is a trusted Mercari seller whose new listings are always announced.
+type Seller struct {
+	ID       string `json:"id"`                  // Mercari seller ID (from jp.mercari.com/user/profile/<id>)
+	Label    string `json:"label"`               // shown in alerts
+	PriceMax int    `json:"price_max,omitempty"` // optional price ceiling
+}
+
+// Notifier names accepted in Config.Notifiers.
+const (
+	NotifierTelegram = "telegram"
+	NotifierDiscord  = "discord"
+	NotifierWebhook  = "webhook"
+)
+
+// HasNotifier reports whether alerts should go to the named destination.
+func (c *Config) HasNotifier(name string) bool {
+	for _, n := range c.Notifiers {
+		if n == name {
+			return true
+		}
+	}
+	return false
 }
 
-// LoadConfig reads and validates config from a JSON file.
+// SearchFilters are a brand's listing filters resolved to Mercari IDs.
+type SearchFilters struct {
+	ConditionIDs     []int
+	SizeIDs          []int
+	ColorIDs         []int
+	ShippingPayerIDs []int
+}
+
+// LoadConfig reads and validates config from a JSON, YAML or TOML file
+// (picked by extension) and the brand files it includes, with credentials
+// overridable from the environment or secret files (see secrets.go).
+// Unknown keys and invalid values are all reported at once in a
+// ValidationError.
 func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	raw, err := readConfigFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read config file %s: %w", path, err)
+		return nil, err
 	}
 
-	cfg := &Config{}
-	if err := json.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("invalid config JSON: %w", err)
+	cfg := &Config{Files: []string{path}}
+	problems, err := decodeStrict(resolveYAML(raw, reflect.TypeOf(cfg)), cfg)
+	if err != nil {
+		return nil, err
 	}
+	included, err := cfg.loadIncludes(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, included...)
+	var v validator
+	cfg.resolveCredentials(filepath.Dir(path), &v)
+	problems = append(problems, v.problems...)
+	cfg.applyDefaults()
+	var invalid ValidationError
+	if errors.As(cfg.Validate(), &invalid) {
+		problems = append(problems, invalid.without(problems)...)
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return cfg, nil
+}
 
-	// Apply defaults
-	if cfg.ScanIntervalMin <= 0 {
+// applyDefaults fills in settings left unset (zero). Negative values are
+// kept so Validate can report them.
+func (cfg *Config) applyDefaults() {
+	if cfg.ScanIntervalMin == 0 {
 		cfg.ScanIntervalMin = 10
 	}
-	if cfg.MaxAgeMinutes <= 0 {
+	if cfg.MaxAgeMinutes == 0 {
 		cfg.MaxAgeMinutes = 180
 	}
-	if cfg.MaxDealsPerBrand <= 0 {
+	if cfg.MaxDealsPerBrand == 0 {
 		cfg.MaxDealsPerBrand = 5
 	}
-	if cfg.PriceMin <= 0 {
+	if cfg.MaxPages == 0 {
+		cfg.MaxPages = 3
+	}
+	if cfg.ScanWorkers == 0 {
+		cfg.ScanWorkers = 3
+	}
+	if cfg.RequestsPerSecond == 0 {
+		cfg.RequestsPerSecond = 1
+	}
+	if cfg.RequestBurst == 0 {
+		cfg.RequestBurst = 3
+	}
+	if cfg.PriceMin == 0 {
 		cfg.PriceMin = 3000
 	}
-	if cfg.PriceMax <= 0 {
+	if cfg.PriceMax == 0 {
 		cfg.PriceMax = 15000
 	}
 	if len(cfg.DefaultCategories) == 0 {
 		cfg.DefaultCategories = []int{1, 2} // Fashion Men/Women
 	}
+	if cfg.Market.RefreshHours == 0 {
+		cfg.Market.RefreshHours = 24
+	}
+	if cfg.Market.MinSamples == 0 {
+		cfg.Market.MinSamples = 5
+	}
+	if cfg.PriceDrop.MinDropPct == 0 {
+		cfg.PriceDrop.MinDropPct = 10
+	}
+	if cfg.PriceDrop.SearchCeilingPct == 0 {
+		cfg.PriceDrop.SearchCeilingPct = 50
+	}
+	if cfg.Recheck.IntervalMinutes == 0 {
+		cfg.Recheck.IntervalMinutes = 15
+	}
+	if cfg.Recheck.WindowHours == 0 {
+		cfg.Recheck.WindowHours = 24
+	}
 	if cfg.HuggingFace.Model == "" {
 		cfg.HuggingFace.Model = "openai/clip-vit-large-patch14"
 	}
-
-	// Validate required fields
-	if cfg.Telegram.BotToken == "" {
-		return nil, fmt.Errorf("telegram.bot_token is required")
-	}
-	if cfg.Telegram.ChatID == "" {
-		return nil, fmt.Errorf("telegram.chat_id is required")
+	if len(cfg.Notifiers) == 0 {
+		cfg.Notifiers = []string{NotifierTelegram}
 	}
-	if len(cfg.Brands) == 0 {
-		return nil, fmt.Errorf("at least one brand is required")
+	for i, s := range cfg.Sellers {
+		if s.Label == "" {
+			cfg.Sellers[i].Label = s.ID
+		}
 	}
-
-	return cfg, nil
 }
 
 // GetPriceRange returns the effective price range for a brand,
@@ -107,3 +302,96 @@ func (c *Config) GetPriceRange(brand Brand) (int, int) {
 	}
 	return pMin, pMax
 }
+
+// GetCategories returns the Mercari categories searched for a brand: its
+// own if set, otherwise default_categories.
+func (c *Config) GetCategories(brand Brand) []int {
+	if len(brand.Categories) > 0 {
+		return brand.Categories
+	}
+	return c.DefaultCategories
+}
+
+// GetScanInterval returns how often a brand is scanned: its own
+// interval_minutes if set, otherwise scan_interval_minutes.
+func (c *Config) GetScanInterval(brand Brand) time.Duration {
+	if brand.IntervalMinutes > 0 {
+		return time.Duration(brand.IntervalMinutes) * time.Minute
+	}
+	return time.Duration(c.ScanIntervalMin) * time.Minute
+}
+
+// CommandChats returns every chat that receives alerts: the default
+// telegram.chat_id followed by the brands' own chats, without duplicates.
+func (c *Config) CommandChats() []string {
+	seen := map[string]bool{}
+	var chats []string
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			chats = append(chats, id)
+		}
+	}
+	add(c.Telegram.ChatID)
+	for _, b := range c.Brands {
+		add(b.ChatID)
+	}
+	return chats
+}
+
+// GetExcludeKeywords returns the global exclude keywords followed by the
+// brand's own, without duplicates.
+func (c *Config) GetExcludeKeywords(brand Brand) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, list := range [][]string{c.ExcludeKeywords, brand.ExcludeKeywords} {
+		for _, kw := range list {
+			key := strings.ToLower(strings.TrimSpace(kw))
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, strings.TrimSpace(kw))
+		}
+	}
+	return out
+}
+
+// GetSearchFilters returns the listing filters for a brand, using the
+// brand's own conditions/sizes/colors/shipping where set and the global
+// ones otherwise. Names were validated by LoadConfig.
+func (c *Config) GetSearchFilters(brand Brand) SearchFilters {
+	f, _ := c.resolveSearchFilters(brand)
+	return f
+}
+
+func (c *Config) resolveSearchFilters(brand Brand) (SearchFilters, error) {
+	conditions, sizes, sizeIDs, colorIDs, shipping := c.Conditions, c.Sizes, c.SizeIDs, c.ColorIDs, c.Shipping
+	if len(brand.Conditions) > 0 {
+		conditions = brand.Conditions
+	}
+	if len(brand.Sizes) > 0 || len(brand.SizeIDs) > 0 {
+		sizes, sizeIDs = brand.Sizes, brand.SizeIDs
+	}
+	if len(brand.ColorIDs) > 0 {
+		colorIDs = brand.ColorIDs
+	}
+	if brand.Shipping != "" {
+		shipping = brand.Shipping
+	}
+
+	var f SearchFilters
+	var err error
+	if f.ConditionIDs, err = mercari.ConditionIDs(conditions); err != nil {
+		return f, err
+	}
+	if f.SizeIDs, err = mercari.SizeIDs(sizes); err != nil {
+		return f, err
+	}
+	f.SizeIDs = append(f.SizeIDs, sizeIDs...)
+	f.ColorIDs = colorIDs
+	if f.ShippingPayerIDs, err = mercari.ShippingPayerIDs(shipping); err != nil {
+		return f, err
+	}
+	return f, nil
+}
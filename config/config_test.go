@@ -0,0 +1,372 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuhoa/autobot/config"
+)
+
+func load(t *testing.T, data string) (*config.Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return config.LoadConfig(path)
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := load(t, `{
+		"telegram": {"bot_token": "TOKEN", "chat_id": "42"},
+		"brands": [{"name": "Undercover", "keywords": ["UNDERCOVER"]}]
+	}`)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.PriceMin != 3000 || cfg.PriceMax != 15000 || cfg.ScanIntervalMin != 10 || len(cfg.Notifiers) != 1 {
+		t.Errorf("defaults not applied: %+v", cfg)
+	}
+}
+
+func TestLoadConfigReportsEveryProblem(t *testing.T) {
+	_, err := load(t, `{
+		"telegram": {"chat_id": "42"},
+		"scan_intervall_minutes": 5,
+		"price_min": -1,
+		"brands": [
+			{"name": "Undercover", "keywords": ["UNDERCOVER"], "price_min": 9000, "price_max": 5000},
+			{"name": "undercover", "keywords": []},
+			{"name": "Kapital", "keywords": ["KAPITAL"], "conditions": ["mint"]}
+		],
+		"sellers": [{"label": "no id"}]
+	}`)
+
+	var problems config.ValidationError
+	if !errors.As(err, &problems) {
+		t.Fatalf("LoadConfig error = %v, want a ValidationError", err)
+	}
+	want := map[string]bool{
+		"scan_intervall_minutes": true,
+		"telegram.bot_token":     true,
+		"price_min":              true,
+		"brands[0].price_min":    true,
+		"brands[1].name":         true,
+		"brands[1].keywords":     true,
+		"brands[2].conditions":   true,
+		"sellers[0].id":          true,
+	}
+	for _, p := range problems {
+		if !want[p.Path] {
+			t.Errorf("unexpected problem %s", p)
+		}
+		delete(want, p.Path)
+	}
+	for path := range want {
+		t.Errorf("no problem reported for %s", path)
+	}
+}
+
+func TestLoadConfigReportsEveryTypeProblem(t *testing.T) {
+	_, err := load(t, `{
+		"telegram": {"bot_token": "TOKEN", "chat_id": -100123},
+		"price_max": "15000",
+		"enable_ai_filter": "yes",
+		"brands": [
+			{"name": "Undercover", "keywords": "UNDERCOVER", "price_max": 9000.5},
+			{"name": "Kapital", "keywords": ["KAPITAL", true], "brand_ids": [12]}
+		]
+	}`)
+
+	var problems config.ValidationError
+	if !errors.As(err, &problems) {
+		t.Fatalf("LoadConfig error = %v, want a ValidationError", err)
+	}
+	want := map[string]bool{
+		"price_max":             true,
+		"enable_ai_filter":      true,
+		"brands[0].keywords":    true,
+		"brands[0].price_max":   true,
+		"brands[1].keywords[1]": true,
+	}
+	for _, p := range problems {
+		if !want[p.Path] {
+			t.Errorf("unexpected problem %s", p)
+		}
+		delete(want, p.Path)
+	}
+	for path := range want {
+		t.Errorf("no problem reported for %s", path)
+	}
+}
+
+func TestNumericChatID(t *testing.T) {
+	cfg, err := load(t, `{
+		"telegram": {"bot_token": "TOKEN", "chat_id": -100123},
+		"brands": [{"name": "Undercover", "keywords": ["UNDERCOVER"], "chat_id": 42}]
+	}`)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Telegram.ChatID != "-100123" || cfg.Brands[0].ChatID != "42" {
+		t.Errorf("chat IDs = %q, %q", cfg.Telegram.ChatID, cfg.Brands[0].ChatID)
+	}
+}
+
+func TestCredentialOverrides(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hf_key"), []byte("hf_filesecret123\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AUTOBOT_TELEGRAM_BOT_TOKEN", "123456:envtoken")
+
+	path := filepath.Join(dir, "config.json")
+	err := os.WriteFile(path, []byte(`{
+		"telegram": {"bot_token": "config-token", "chat_id": "42"},
+		"huggingface": {"api_key_file": "hf_key"},
+		"brands": [{"name": "Undercover", "keywords": ["UNDERCOVER"]}]
+	}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Telegram.BotToken != "123456:envtoken" || cfg.HuggingFace.APIKey != "hf_filesecret123" || cfg.Telegram.ChatID != "42" {
+		t.Fatalf("overrides not applied: %+v %+v", cfg.Telegram, cfg.HuggingFace)
+	}
+
+	sources := map[string]config.CredentialSource{}
+	for _, s := range cfg.CredentialSources {
+		sources[s.Path] = s
+	}
+	if s := sources["telegram.bot_token"]; s.Source != "env AUTOBOT_TELEGRAM_BOT_TOKEN" || s.Value == "123456:envtoken" {
+		t.Errorf("bot token source = %+v, want masked env", s)
+	}
+	if s := sources["huggingface.api_key"]; s.Source != "file hf_key" {
+		t.Errorf("api key source = %+v, want file", s)
+	}
+
+	// A missing secret file is a config problem, not an empty credential
+	t.Setenv("AUTOBOT_TELEGRAM_CHAT_ID_FILE", filepath.Join(dir, "missing"))
+	var problems config.ValidationError
+	if _, err := config.LoadConfig(path); !errors.As(err, &problems) {
+		t.Fatalf("LoadConfig with a missing secret file = %v, want a ValidationError", err)
+	}
+}
+
+// writeFiles writes name → content files into a temp dir and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	files := map[string]string{
+		"config.json": `{
+			"telegram": {"bot_token": "TOKEN", "chat_id": "-100123"},
+			"price_max": 20000,
+			"requests_per_second": 0.5,
+			"enable_ai_filter": true,
+			"brands": [
+				{"name": "Kapital", "keywords": ["KAPITAL", "キャピタル"], "price_max": 9000},
+				{"name": "C#: Sharp", "keywords": ["it's"], "brand_ids": [12, 34]}
+			]
+		}`,
+		"config.yaml": `
+# Telegram chat IDs stay strings even unquoted
+telegram:
+  bot_token: TOKEN
+  chat_id: -100123   # supergroup
+price_max: 20000
+requests_per_second: 0.5
+enable_ai_filter: true
+brands:
+  - name: Kapital
+    keywords: [KAPITAL, "キャピタル"]
+    price_max: 9000
+  - name: 'C#: Sharp'
+    keywords:
+    - it's
+    brand_ids: [
+      12, 34,
+    ]
+`,
+		"config.toml": `
+price_max = 20_000
+requests_per_second = 0.5
+enable_ai_filter = true
+
+[telegram]
+bot_token = "TOKEN"
+chat_id = '-100123' # supergroup
+
+[[brands]]
+name = "Kapital"
+keywords = ["KAPITAL", "キャピタル"]
+price_max = 9000
+
+[[brands]]
+name = "C#: Sharp"
+keywords = ["it's"]
+brand_ids = [
+  12,
+  34, # trailing comma
+]
+`,
+	}
+	dir := writeFiles(t, files)
+
+	want, err := config.LoadConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatalf("LoadConfig(json): %v", err)
+	}
+	for _, name := range []string{"config.yaml", "config.toml"} {
+		got, err := config.LoadConfig(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("LoadConfig(%s): %v", name, err)
+			continue
+		}
+		got.Files, want.Files = nil, nil
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s decoded as\n%+v\nwant\n%+v", name, got, want)
+		}
+	}
+}
+
+func TestLoadConfigQuoting(t *testing.T) {
+	brands := func(keywords ...string) string {
+		return "telegram: {bot_token: T, chat_id: '1'}\nbrands:\n" +
+			"  - name: A\n    keywords: " + keywords[0] + "\n" +
+			"  - name: B\n    keywords: [b]\n"
+	}
+	tests := []struct {
+		name string
+		file string
+		data string
+		want []string
+	}{
+		{"yaml apostrophe in flow list", "c.yaml", brands("[Y's]"), []string{"Y's"}},
+		{"yaml apostrophes and commas", "c.yaml", brands(`[Y's, "a, b", 'it''s']`), []string{"Y's", "a, b", "it's"}},
+		{"yaml colon and hash", "c.yaml", brands(`["C#: Sharp", a#b]`), []string{"C#: Sharp", "a#b"}},
+		{"yaml block list", "c.yaml", brands("\n      - Y's\n      - \"quoted \\\" inside\""), []string{"Y's", `quoted " inside`}},
+		{"toml apostrophe in basic string", "c.toml",
+			"[telegram]\nbot_token = \"T\"\nchat_id = \"1\"\n[[brands]]\nname = \"A\"\nkeywords = [\"Y's\", 'it\"s', \"\"\"x\"\"\"]\n[[brands]]\nname = \"B\"\nkeywords = [\"b\"]\n",
+			[]string{"Y's", `it"s`, "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{tt.file: tt.data})
+			cfg, err := config.LoadConfig(filepath.Join(dir, tt.file))
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if len(cfg.Brands) != 2 || cfg.Brands[1].Name != "B" {
+				t.Fatalf("brands = %+v, want A and B", cfg.Brands)
+			}
+			if !reflect.DeepEqual(cfg.Brands[0].Keywords, tt.want) {
+				t.Errorf("keywords = %q, want %q", cfg.Brands[0].Keywords, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigSyntaxErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"config.yaml": "telegram:\n  bot_token: x\n    chat_id: y\n",
+		"config.toml": "[telegram]\nbot_token = \"x\nchat_id = \"y\"\n",
+		"config.ini":  "bot_token = x\n",
+	} {
+		dir := writeFiles(t, map[string]string{name: data})
+		_, err := config.LoadConfig(filepath.Join(dir, name))
+		var problems config.ValidationError
+		if err == nil || errors.As(err, &problems) {
+			t.Errorf("LoadConfig(%s) = %v, want a parse error", name, err)
+		} else if name != "config.ini" && !strings.Contains(err.Error(), "line 2") && !strings.Contains(err.Error(), "line 3") {
+			t.Errorf("LoadConfig(%s) = %v, want the line", name, err)
+		}
+	}
+}
+
+func TestLoadConfigIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"bot.yaml": `
+telegram: {bot_token: TOKEN, chat_id: "42"}
+price_max: 12000
+include: ["brands/*.yaml", brands/extra.json]
+brands:
+  - name: Kapital
+    keywords: [KAPITAL]
+`,
+		"brands/archive.yaml": `
+- name: Undercover
+  keywords: [UNDERCOVER]
+- name: Number (N)ine
+  keywords: [NUMBER NINE]
+`,
+		"brands/street.yaml": `
+brands:
+  - name: Supreme
+    keywords: [SUPREME]
+`,
+		"brands/extra.json": `[{"name": "Visvim", "keywords": ["VISVIM"], "price_max": 30000}]`,
+	})
+
+	cfg, err := config.LoadConfig(filepath.Join(dir, "bot.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	var names []string
+	for _, b := range cfg.Brands {
+		names = append(names, b.Name)
+	}
+	if want := "Kapital,Undercover,Number (N)ine,Supreme,Visvim"; strings.Join(names, ",") != want {
+		t.Errorf("brands = %v, want %s", names, want)
+	}
+	if len(cfg.Files) != 4 {
+		t.Errorf("files = %v, want the main file and 3 includes", cfg.Files)
+	}
+	if _, pMax := cfg.GetPriceRange(cfg.Brands[3]); pMax != 12000 {
+		t.Errorf("included brand price_max = %d, want the instance's 12000", pMax)
+	}
+
+	// A brand defined in two included files is reported in the second one
+	if err := os.WriteFile(filepath.Join(dir, "brands/street.yaml"),
+		[]byte("- name: UNDERCOVER\n  keywords: [UC]\n- name: Stussy\n  keywords: []\n  typo: 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = config.LoadConfig(filepath.Join(dir, "bot.yaml"))
+	var problems config.ValidationError
+	if !errors.As(err, &problems) {
+		t.Fatalf("LoadConfig = %v, want a ValidationError", err)
+	}
+	want := map[string]bool{
+		"brands/street.yaml: brands[0].name: duplicate of brands/archive.yaml brands[0] (\"UNDERCOVER\")": true,
+		"brands/street.yaml: brands[1].keywords: empty (set keywords or brand_ids)":                       true,
+		"brands/street.yaml: brands[1].typo: unknown field":                                               true,
+	}
+	for _, p := range problems {
+		if !want[p.String()] {
+			t.Errorf("unexpected problem %s", p)
+		}
+		delete(want, p.String())
+	}
+	for p := range want {
+		t.Errorf("missing problem %s", p)
+	}
+}
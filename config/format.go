@@ -0,0 +1,58 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readConfigFile parses a config file into maps, slices and scalars, using
+// the format its extension names: .json, .yaml/.yml or .toml.
+func readConfigFile(path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file %s: %w", path, err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return parseJSON(data)
+	case ".yaml", ".yml":
+		v, err := parseYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config YAML: %w", err)
+		}
+		return v, nil
+	case ".toml":
+		v, err := parseTOML(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config TOML: %w", err)
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unsupported config format %q for %s (want .json, .yaml, .yml or .toml)", ext, path)
+	}
+}
+
+func parseJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after the top-level value")
+	}
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+		return nil, fmt.Errorf("invalid config JSON at line %d: %w", line, err)
+	case err != nil:
+		return nil, fmt.Errorf("invalid config JSON: %w", err)
+	}
+	return v, nil
+}
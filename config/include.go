@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+)
+
+// brandFile is a file named by "include": a list of brands, or an object
+// with a "brands" list. Like the main config it may be JSON, YAML or TOML.
+type brandFile struct {
+	Brands []Brand `json:"brands"`
+}
+
+// loadIncludes appends the brands of every file matched by c.Include to
+// c.Brands. Patterns are globs relative to dir, the main file's directory;
+// a file matched twice is read once. Brands keep the file they came from,
+// so Validate reports their problems (and duplicates across files) there.
+func (c *Config) loadIncludes(dir string) (ValidationError, error) {
+	var problems ValidationError
+	seen := make(map[string]bool)
+	for _, path := range c.Files {
+		seen[path] = true
+	}
+
+	for i, pattern := range c.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err == nil && len(matches) == 0 {
+			err = fmt.Errorf("no file matches %q", c.Include[i])
+		}
+		if err != nil {
+			problems = append(problems, Problem{Path: fmt.Sprintf("include[%d]", i), Message: err.Error()})
+			continue
+		}
+		for _, path := range matches {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			more, err := c.include(dir, path)
+			if err != nil {
+				return nil, err
+			}
+			problems = append(problems, more...)
+		}
+	}
+	return problems, nil
+}
+
+func (c *Config) include(dir, path string) (ValidationError, error) {
+	name := path
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		name = rel
+	}
+	raw, err := readConfigFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if list, ok := raw.([]interface{}); ok {
+		raw = map[string]interface{}{"brands": list}
+	}
+
+	var f brandFile
+	problems, err := decodeStrict(resolveYAML(raw, reflect.TypeOf(f)), &f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	for i := range problems {
+		problems[i].File = name
+	}
+	for i, b := range f.Brands {
+		b.file, b.index = name, i
+		c.Brands = append(c.Brands, b)
+	}
+	c.Files = append(c.Files, path)
+	return problems, nil
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Credentials can be kept out of config.json. For each setting below the
+// first of these wins:
+//
+//	AUTOBOT_<NAME>          environment variable
+//	AUTOBOT_<NAME>_FILE     environment variable naming a file
+//	<key>_file              config key naming a file (e.g. a Docker secret
+//	                        or systemd credential)
+//	<key>                   the plain config value
+//
+// Files are read whole with surrounding whitespace trimmed; relative paths
+// are resolved against the config file's directory.
+
+// CredentialSource reports where a credential setting was taken from.
+type CredentialSource struct {
+	Path   string // config path, e.g. "telegram.bot_token"
+	Source string // "config", "env AUTOBOT_...", "file /run/secrets/..."
+	Value  string // masked unless the setting is not secret
+}
+
+func (s CredentialSource) String() string {
+	return fmt.Sprintf("%s=%s (%s)", s.Path, s.Value, s.Source)
+}
+
+type credential struct {
+	path   string
+	env    string
+	value  *string
+	file   *string // nil if the setting has no *_file key
+	secret bool
+}
+
+func (c *Config) credentials() []credential {
+	return []credential{
+		{"telegram.bot_token", "AUTOBOT_TELEGRAM_BOT_TOKEN", &c.Telegram.BotToken, &c.Telegram.BotTokenFile, true},
+		{"telegram.chat_id", "AUTOBOT_TELEGRAM_CHAT_ID", &c.Telegram.ChatID, &c.Telegram.ChatIDFile, true},
+		{"huggingface.api_key", "AUTOBOT_HUGGINGFACE_API_KEY", &c.HuggingFace.APIKey, &c.HuggingFace.APIKeyFile, true},
+		{"huggingface.model", "AUTOBOT_HUGGINGFACE_MODEL", &c.HuggingFace.Model, nil, false},
+	}
+}
+
+// resolveCredentials applies environment and file overrides and records
+// their sources in c.CredentialSources. dir is the config file's directory.
+func (c *Config) resolveCredentials(dir string, v *validator) {
+	c.CredentialSources = nil
+	for _, cr := range c.credentials() {
+		source := "config"
+		if val, ok := os.LookupEnv(cr.env); ok {
+			*cr.value, source = val, "env "+cr.env
+		} else if path, ok := os.LookupEnv(cr.env + "_FILE"); ok {
+			if val, err := readSecret(dir, path); err != nil {
+				v.add(cr.path, "%s: %v", cr.env+"_FILE", err)
+			} else {
+				*cr.value, source = val, "file "+path+" via "+cr.env+"_FILE"
+			}
+		} else if cr.file != nil && *cr.file != "" {
+			if val, err := readSecret(dir, *cr.file); err != nil {
+				v.add(cr.path+"_file", "%v", err)
+			} else {
+				*cr.value, source = val, "file "+*cr.file
+			}
+		}
+
+		if *cr.value == "" {
+			continue
+		}
+		shown := *cr.value
+		if cr.secret {
+			shown = mask(shown)
+		}
+		c.CredentialSources = append(c.CredentialSources, CredentialSource{Path: cr.path, Source: source, Value: shown})
+	}
+}
+
+func readSecret(dir, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// mask keeps just enough of a secret to tell two values apart.
+func mask(s string) string {
+	if len(s) < 10 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:2] + "…" + s[len(s)-2:]
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+)
+
+// parseTOML parses a TOML document into maps, slices and scalars. Integers
+// become json.Number like in JSON files; dates and times are rejected.
+func parseTOML(data []byte) (interface{}, error) {
+	var doc map[string]interface{}
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, err
+	}
+	return tomlValue(doc, "")
+}
+
+func tomlValue(value interface{}, path string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, elem := range v {
+			conv, err := tomlValue(elem, joinPath(path, k))
+			if err != nil {
+				return nil, err
+			}
+			v[k] = conv
+		}
+		return v, nil
+	case []map[string]interface{}: // [[array of tables]]
+		list := make([]interface{}, len(v))
+		for i, elem := range v {
+			conv, err := tomlValue(elem, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = conv
+		}
+		return list, nil
+	case []interface{}:
+		for i, elem := range v {
+			conv, err := tomlValue(elem, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = conv
+		}
+		return v, nil
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), nil
+	case string, bool, float64:
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported TOML value %v (%T)", path, value, value)
+}
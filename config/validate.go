@@ -0,0 +1,439 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xuhoa/autobot/pkg/mercari"
+)
+
+// Problem is one invalid setting, located by its JSON path
+// (e.g. "brands[7].keywords") and, for included files, the file.
+type Problem struct {
+	File    string // included file, relative to the main config (empty for the main file)
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	s := p.Message
+	if p.Path != "" {
+		s = p.Path + ": " + s
+	}
+	if p.File != "" {
+		s = p.File + ": " + s
+	}
+	return s
+}
+
+// ValidationError lists every problem found in a config file.
+type ValidationError []Problem
+
+func (e ValidationError) Error() string {
+	if len(e) == 1 {
+		return "invalid config: " + e[0].String()
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "invalid config (%d problems):", len(e))
+	for _, p := range e {
+		sb.WriteString("\n  - " + p.String())
+	}
+	return sb.String()
+}
+
+// without drops the problems at a setting already reported in other, so a
+// value of the wrong type is not reported again as missing.
+func (e ValidationError) without(other ValidationError) ValidationError {
+	reported := make(map[Problem]bool)
+	for _, p := range other {
+		reported[Problem{File: p.File, Path: p.Path}] = true
+	}
+	var kept ValidationError
+	for _, p := range e {
+		if !reported[Problem{File: p.File, Path: p.Path}] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// validator collects problems instead of stopping at the first one.
+type validator struct {
+	problems ValidationError
+	file     string // included file the problems being added are in
+}
+
+func (v *validator) add(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{File: v.file, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) nonNegative(path string, n float64) {
+	if n < 0 {
+		v.add(path, "must not be negative (got %v)", n)
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return v.problems
+}
+
+// ---------- Decoding ----------
+
+// decodeStrict decodes a parsed config file into out. Keys that match no
+// setting are all reported (with a suggestion for likely typos) as
+// problems, as is every value of the wrong type; the rest of the file is
+// still decoded so Validate can report its problems too.
+func decodeStrict(raw interface{}, out interface{}) (problems ValidationError, err error) {
+	var v validator
+	v.checkFields(raw, reflect.TypeOf(out), "")
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if len(v.problems) > 0 {
+		err = json.Unmarshal(data, out) // skips the keys and values reported above
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(out)
+	}
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		if len(v.problems) == 0 {
+			v.add(bracketPath(typeErr.Field), "expected %s, got %s", typeErr.Type, typeErr.Value)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return v.problems, nil
+}
+
+// checkFields walks decoded JSON alongside the Go type it is meant for and
+// reports object keys that have no matching field and values of the wrong
+// type. Like encoding/json, keys match case-insensitively and null is
+// accepted anywhere.
+func (v *validator) checkFields(value interface{}, t reflect.Type, path string) {
+	if value == nil {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		v.checkFields(value, t.Elem(), path)
+	case reflect.Slice:
+		list, ok := value.([]interface{})
+		if !ok {
+			v.add(path, "expected a list, got %s", describeValue(value))
+			return
+		}
+		for i, elem := range list {
+			v.checkFields(elem, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Struct:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			v.add(path, "expected an object, got %s", describeValue(value))
+			return
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := joinPath(path, k)
+			field, ok := fields[strings.ToLower(k)]
+			if !ok {
+				if guess := closestKey(k, fields); guess != "" {
+					v.add(p, "unknown field (did you mean %q?)", guess)
+				} else {
+					v.add(p, "unknown field")
+				}
+				continue
+			}
+			v.checkFields(obj[k], field.Type, p)
+		}
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			v.add(path, "expected a string, got %s", describeValue(value))
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			v.add(path, "expected true or false, got %s", describeValue(value))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !isInteger(value) {
+			v.add(path, "expected a whole number, got %s", describeValue(value))
+		}
+	case reflect.Float32, reflect.Float64:
+		switch value.(type) {
+		case json.Number, float64:
+		default:
+			v.add(path, "expected a number, got %s", describeValue(value))
+		}
+	}
+}
+
+func isInteger(value interface{}) bool {
+	switch n := value.(type) {
+	case json.Number:
+		_, err := strconv.ParseInt(string(n), 10, 64)
+		return err == nil
+	case float64:
+		return n == math.Trunc(n) && math.Abs(n) < 1<<53
+	}
+	return false
+}
+
+// describeValue names a decoded value for type problems.
+func describeValue(value interface{}) string {
+	switch x := value.(type) {
+	case string:
+		return fmt.Sprintf("string %q", x)
+	case json.Number, float64:
+		return fmt.Sprintf("number %v", x)
+	case bool:
+		return fmt.Sprintf("%v", x)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// bracketPath turns an encoding/json field path like "brands.0.price_max"
+// into "brands[0].price_max".
+func bracketPath(field string) string {
+	var sb strings.Builder
+	for i, part := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(part); err == nil && i > 0 {
+			sb.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(part)
+	}
+	return sb.String()
+}
+
+// jsonFields maps the lowercased JSON names of a struct's fields to them.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = f
+	}
+	return fields
+}
+
+// closestKey suggests the known key nearest to a misspelt one, if any is
+// within a couple of edits.
+func closestKey(key string, fields map[string]reflect.StructField) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		if d := editDistance(strings.ToLower(key), name); d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// ---------- Validation ----------
+
+// Validate checks the whole config and reports every problem found, each
+// with its JSON path. It expects defaults to have been applied.
+func (c *Config) Validate() error {
+	var v validator
+
+	// Notifiers and their credentials
+	for i, name := range c.Notifiers {
+		switch name {
+		case NotifierTelegram:
+			if c.Telegram.BotToken == "" {
+				v.add("telegram.bot_token", "required by the telegram notifier")
+			}
+			if c.Telegram.ChatID == "" {
+				v.add("telegram.chat_id", "required by the telegram notifier")
+			}
+		case NotifierDiscord:
+			if c.Discord.WebhookURL == "" {
+				v.add("discord.webhook_url", "required by the discord notifier")
+			}
+		case NotifierWebhook:
+			if len(c.Webhooks) == 0 {
+				v.add("webhooks", "at least one endpoint is required by the webhook notifier")
+			}
+		default:
+			v.add(fmt.Sprintf("notifiers[%d]", i), "unknown notifier %q (want %s, %s or %s)",
+				name, NotifierTelegram, NotifierDiscord, NotifierWebhook)
+		}
+	}
+	for i, w := range c.Webhooks {
+		v.httpURL(fmt.Sprintf("webhooks[%d].url", i), w.URL)
+	}
+	if c.Discord.WebhookURL != "" {
+		v.httpURL("discord.webhook_url", c.Discord.WebhookURL)
+	}
+
+	// Global search settings
+	v.nonNegative("price_min", float64(c.PriceMin))
+	v.nonNegative("price_max", float64(c.PriceMax))
+	if c.PriceMin > 0 && c.PriceMax > 0 && c.PriceMin > c.PriceMax {
+		v.add("price_min", "greater than price_max (%d > %d)", c.PriceMin, c.PriceMax)
+	}
+	v.nonNegative("scan_interval_minutes", float64(c.ScanIntervalMin))
+	v.nonNegative("max_age_minutes", float64(c.MaxAgeMinutes))
+	v.nonNegative("max_deals_per_keyword", float64(c.MaxDealsPerBrand))
+	v.nonNegative("max_pages", float64(c.MaxPages))
+	v.nonNegative("scan_workers", float64(c.ScanWorkers))
+	v.nonNegative("requests_per_second", c.RequestsPerSecond)
+	v.nonNegative("request_burst", float64(c.RequestBurst))
+	v.nonNegative("dpop_key_rotation_days", float64(c.DPoPKeyRotationDays))
+	v.nonNegative("market.refresh_hours", float64(c.Market.RefreshHours))
+	v.nonNegative("market.min_samples", float64(c.Market.MinSamples))
+	v.nonNegative("market.min_below_pct", c.Market.MinBelowPct)
+	v.nonNegative("price_drop.min_drop_pct", c.PriceDrop.MinDropPct)
+	v.nonNegative("price_drop.search_ceiling_pct", c.PriceDrop.SearchCeilingPct)
+	v.nonNegative("recheck.interval_minutes", float64(c.Recheck.IntervalMinutes))
+	v.nonNegative("recheck.window_hours", float64(c.Recheck.WindowHours))
+	v.filters("", c.Conditions, c.Sizes, c.Shipping)
+
+	// Brands and sellers
+	if len(c.Brands) == 0 && len(c.Sellers) == 0 {
+		v.add("brands", "at least one brand or seller is required")
+	}
+	names := make(map[string]string)
+	for i, b := range c.Brands {
+		path := fmt.Sprintf("brands[%d]", i)
+		if v.file = b.file; b.file != "" {
+			path = fmt.Sprintf("brands[%d]", b.index)
+		}
+		key := strings.ToLower(strings.TrimSpace(b.Name))
+		switch first, dup := names[key]; {
+		case key == "":
+			v.add(path+".name", "required")
+		case dup:
+			v.add(path+".name", "duplicate of %s (%q)", first, b.Name)
+		case b.file != "":
+			names[key] = b.file + " " + path
+		default:
+			names[key] = path
+		}
+		if len(b.Keywords) == 0 && len(b.BrandIDs) == 0 {
+			v.add(path+".keywords", "empty (set keywords or brand_ids)")
+		}
+		for j, kw := range b.Keywords {
+			if strings.TrimSpace(kw) == "" {
+				v.add(fmt.Sprintf("%s.keywords[%d]", path, j), "empty")
+			}
+		}
+		v.nonNegative(path+".price_min", float64(b.PriceMin))
+		v.nonNegative(path+".price_max", float64(b.PriceMax))
+		if pMin, pMax := c.GetPriceRange(b); pMin > 0 && pMax > 0 && pMin > pMax {
+			v.add(path+".price_min", "greater than price_max (%d > %d)", pMin, pMax)
+		}
+		v.nonNegative(path+".message_thread_id", float64(b.MessageThreadID))
+		v.nonNegative(path+".interval_minutes", float64(b.IntervalMinutes))
+		for j, id := range b.Categories {
+			if id <= 0 {
+				v.add(fmt.Sprintf("%s.categories[%d]", path, j), "must be a positive Mercari category ID (got %d)", id)
+			}
+		}
+		v.filters(path, b.Conditions, b.Sizes, b.Shipping)
+	}
+	v.file = ""
+
+	sellers := make(map[string]int)
+	for i, s := range c.Sellers {
+		path := fmt.Sprintf("sellers[%d]", i)
+		switch first, dup := sellers[s.ID]; {
+		case s.ID == "":
+			v.add(path+".id", "required")
+		case dup:
+			v.add(path+".id", "duplicate of sellers[%d]", first)
+		default:
+			sellers[s.ID] = i
+		}
+		v.nonNegative(path+".price_max", float64(s.PriceMax))
+	}
+
+	return v.err()
+}
+
+// filters checks listing filter names, so typos fail at startup rather
+// than mid-scan.
+func (v *validator) filters(path string, conditions, sizes []string, shipping string) {
+	if _, err := mercari.ConditionIDs(conditions); err != nil {
+		v.add(joinPath(path, "conditions"), "%v", err)
+	}
+	if _, err := mercari.SizeIDs(sizes); err != nil {
+		v.add(joinPath(path, "sizes"), "%v", err)
+	}
+	if _, err := mercari.ShippingPayerIDs(shipping); err != nil {
+		v.add(joinPath(path, "shipping"), "%v", err)
+	}
+}
+
+func (v *validator) httpURL(path, raw string) {
+	if raw == "" {
+		v.add(path, "required")
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(path, "not an http(s) URL")
+	}
+}
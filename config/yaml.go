@@ -0,0 +1,178 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlPlain is an unquoted scalar. Whether it is a string, number, bool or
+// null is settled by resolveYAML once the setting it is for is known, so
+// chat_id: -1001234 stays a string while price_max: 9000 is a number.
+type yamlPlain string
+
+// parseYAML parses a single YAML document into maps, slices and scalars.
+// Quoted and block scalars are strings; plain ones are left as yamlPlain.
+func parseYAML(data []byte) (interface{}, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 {
+		return map[string]interface{}{}, nil // empty file
+	}
+	return yamlValue(&doc)
+}
+
+func yamlValue(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		return yamlValue(n.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(n.Alias)
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(n.Content))
+		for _, elem := range n.Content {
+			v, err := yamlValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case yaml.MappingNode:
+		obj := make(map[string]interface{}, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+			}
+			if key.Tag == "!!merge" {
+				if err := mergeYAML(obj, val); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			v, err := yamlValue(val)
+			if err != nil {
+				return nil, err
+			}
+			obj[key.Value] = v
+		}
+		return obj, nil
+	case yaml.ScalarNode:
+		if n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0 || n.Tag == "!!str" {
+			return n.Value, nil
+		}
+		return yamlPlain(n.Value), nil
+	}
+	return nil, fmt.Errorf("line %d: unsupported YAML node", n.Line)
+}
+
+// mergeYAML applies a "<<" merge key: keys from the merged mapping(s) are
+// added unless obj already has them.
+func mergeYAML(obj map[string]interface{}, n *yaml.Node) error {
+	v, err := yamlValue(n)
+	if err != nil {
+		return err
+	}
+	sources, ok := v.([]interface{})
+	if !ok {
+		sources = []interface{}{v}
+	}
+	for _, src := range sources {
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("line %d: << must merge a mapping", n.Line)
+		}
+		for k, val := range m {
+			if _, set := obj[k]; !set {
+				obj[k] = val
+			}
+		}
+	}
+	return nil
+}
+
+// resolveYAML gives plain scalars their type. One meant for a string
+// setting keeps its text; any other is read as null, bool, integer or
+// float where it looks like one. Integers meant for a string setting
+// become strings too, in any format. t is the Go type the value decodes into,
+// or nil when unknown.
+func resolveYAML(value interface{}, t reflect.Type) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		var fields map[string]reflect.StructField
+		if t != nil && t.Kind() == reflect.Struct {
+			fields = jsonFields(t)
+		}
+		for k, elem := range v {
+			var ft reflect.Type
+			if f, ok := fields[strings.ToLower(k)]; ok {
+				ft = f.Type
+			}
+			v[k] = resolveYAML(elem, ft)
+		}
+	case []interface{}:
+		var et reflect.Type
+		if t != nil && t.Kind() == reflect.Slice {
+			et = t.Elem()
+		}
+		for i, elem := range v {
+			v[i] = resolveYAML(elem, et)
+		}
+	case yamlPlain:
+		if t != nil && t.Kind() == reflect.String && !isYAMLNull(string(v)) {
+			return string(v)
+		}
+		return resolvePlain(string(v))
+	case json.Number:
+		if t != nil && t.Kind() == reflect.String {
+			return string(v) // a bare chat_id in JSON or TOML
+		}
+	}
+	return value
+}
+
+func isYAMLNull(s string) bool {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return true
+	}
+	return false
+}
+
+func resolvePlain(s string) interface{} {
+	switch s {
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if isYAMLNull(s) {
+		return nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return json.Number(strconv.FormatInt(n, 10))
+	}
+	for prefix, base := range map[string]int{"0x": 16, "0o": 8} {
+		if rest, ok := strings.CutPrefix(s, prefix); ok {
+			if n, err := strconv.ParseInt(rest, base, 64); err == nil {
+				return json.Number(strconv.FormatInt(n, 10))
+			}
+		}
+	}
+	if strings.Trim(s, "0123456789+-.eE") == "" && strings.ContainsAny(s, "0123456789") {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
@@ -2,7 +2,11 @@ module github.com/xuhoa/autobot
 
 go 1.21
 
-require modernc.org/sqlite v1.28.0
+require (
+	github.com/BurntSushi/toml v1.4.0
+	gopkg.in/yaml.v3 v3.0.1
+	modernc.org/sqlite v1.28.0
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
@@ -0,0 +1,20 @@
+// Package clock holds the context-aware wait shared by the scanner, the
+// notifiers and the bot's loops.
+package clock
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep waits for d or until ctx is done, returning ctx's error if cut short.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
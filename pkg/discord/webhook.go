@@ -0,0 +1,248 @@
+// Package discord sends deal notifications to a Discord channel through an
+// incoming webhook.
+//
+// Deals are rendered as embeds (title linking to the listing, product image,
+// price/brand fields); status messages are plain content.
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/clock"
+	"github.com/xuhoa/autobot/pkg/notify"
+)
+
+// Embed colours
+const (
+	colorDeal    = 0xE74C3C // red, like the Telegram 🔥
+	colorWatched = 0xF1C40F // gold for watched sellers
+	colorError   = 0x992D22
+)
+
+// Notifier posts alerts to a Discord webhook. It implements notify.Notifier.
+type Notifier struct {
+	webhookURL string
+	username   string
+	client     *http.Client
+}
+
+// NotifierOption configures optional Notifier behaviour.
+type NotifierOption func(*Notifier)
+
+// WithUsername overrides the name the webhook posts as.
+func WithUsername(name string) NotifierOption {
+	return func(n *Notifier) {
+		n.username = name
+	}
+}
+
+// NewNotifier creates a Discord notifier for the given webhook URL
+// (https://discord.com/api/webhooks/<id>/<token>).
+func NewNotifier(webhookURL string, opts ...NotifierOption) *Notifier {
+	n := &Notifier{
+		webhookURL: webhookURL,
+		username:   "AutoBot",
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// ---------- Discord webhook structs ----------
+
+type webhookMessage struct {
+	Username string  `json:"username,omitempty"`
+	Content  string  `json:"content,omitempty"`
+	Embeds   []embed `json:"embeds,omitempty"`
+}
+
+type embed struct {
+	Title       string       `json:"title,omitempty"`
+	URL         string       `json:"url,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Color       int          `json:"color,omitempty"`
+	Fields      []embedField `json:"fields,omitempty"`
+	Image       *embedImage  `json:"image,omitempty"`
+	Footer      *embedFooter `json:"footer,omitempty"`
+}
+
+type embedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type embedImage struct {
+	URL string `json:"url"`
+}
+
+type embedFooter struct {
+	Text string `json:"text"`
+}
+
+type rateLimitResponse struct {
+	RetryAfter float64 `json:"retry_after"` // seconds
+}
+
+// ---------- Public methods ----------
+
+// SendDeal posts a deal as an embed with the product photo.
+func (n *Notifier) SendDeal(ctx context.Context, deal notify.DealItem) error {
+	return n.post(ctx, webhookMessage{Embeds: []embed{dealEmbed(deal)}})
+}
+
+// SendStartup posts a startup notification.
+func (n *Notifier) SendStartup(ctx context.Context, brandCount int, scanInterval int) error {
+	msg := fmt.Sprintf("🤖 **AutoBot Started!**\n🔍 Watching **%d brands** · ⏰ every **%d minutes**\n🟢 Ready to hunt deals!",
+		brandCount, scanInterval)
+	return n.post(ctx, webhookMessage{Content: msg})
+}
+
+// SendError posts an error notification.
+func (n *Notifier) SendError(ctx context.Context, errMsg string) error {
+	return n.post(ctx, webhookMessage{Embeds: []embed{{
+		Title:       "🔴 AutoBot Error",
+		Description: "```\n" + notify.Truncate(errMsg, 1900) + "\n```",
+		Color:       colorError,
+	}}})
+}
+
+// SendScanSummary posts a summary after a scan cycle that found new items.
+func (n *Notifier) SendScanSummary(ctx context.Context, totalFound, totalNew, totalKept int, duration time.Duration) error {
+	if totalNew == 0 {
+		return nil // don't spam if nothing new
+	}
+	msg := fmt.Sprintf("📊 **Scan Complete** — Found: %d | New: %d | Sent: %d · ⏱ %s",
+		totalFound, totalNew, totalKept, duration.Round(time.Second))
+	return n.post(ctx, webhookMessage{Content: msg})
+}
+
+// ---------- Private methods ----------
+
+// post sends one webhook message, retrying once if Discord rate-limits us.
+func (n *Notifier) post(ctx context.Context, msg webhookMessage) error {
+	if msg.Username == "" {
+		msg.Username = n.username
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshaling discord message: %w", err)
+	}
+
+	retryAfter, err := n.doRequest(ctx, body)
+	if err == nil || retryAfter <= 0 {
+		return err
+	}
+	if err := clock.Sleep(ctx, retryAfter); err != nil {
+		return err
+	}
+	_, err = n.doRequest(ctx, body)
+	return err
+}
+
+// doRequest posts body to the webhook. On HTTP 429 it also returns how long
+// Discord asked us to wait.
+func (n *Notifier) doRequest(ctx context.Context, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", n.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("creating discord request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("discord request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+
+	err = fmt.Errorf("discord webhook HTTP %d: %s", resp.StatusCode, string(respBody))
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return 0, err
+	}
+	var rl rateLimitResponse
+	if json.Unmarshal(respBody, &rl) == nil && rl.RetryAfter > 0 {
+		return time.Duration(rl.RetryAfter * float64(time.Second)), err
+	}
+	if secs, convErr := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); convErr == nil {
+		return time.Duration(secs * float64(time.Second)), err
+	}
+	return time.Second, err
+}
+
+// ---------- Formatting ----------
+
+func dealEmbed(deal notify.DealItem) embed {
+	e := embed{
+		Title: "🔥 " + notify.Truncate(deal.Name, 240),
+		URL:   deal.ItemURL,
+		Color: colorDeal,
+	}
+	if deal.WatchedSeller != "" {
+		e.Title = "⭐ " + notify.Truncate(deal.Name, 240)
+		e.Color = colorWatched
+		e.Footer = &embedFooter{Text: "Watched seller: " + deal.WatchedSeller}
+	}
+	if deal.PreviousPrice > 0 {
+		e.Title = "💸 " + notify.Truncate(deal.Name, 240)
+	}
+	if deal.ImageURL != "" {
+		e.Image = &embedImage{URL: deal.ImageURL}
+	}
+	if deal.Description != "" {
+		e.Description = notify.Truncate(deal.Description, 300)
+	}
+
+	price := "¥" + notify.FormatPrice(deal.Price)
+	if deal.PreviousPrice > 0 {
+		price = "~~¥" + notify.FormatPrice(deal.PreviousPrice) + "~~ → " + price
+	}
+	e.Fields = append(e.Fields, embedField{Name: "Price", Value: price, Inline: true})
+	if deal.MarketMedian > 0 {
+		var v string
+		if deal.BelowMarketPct >= 0 {
+			v = fmt.Sprintf("📉 %.0f%% below (median ¥%s)", deal.BelowMarketPct, notify.FormatPrice(deal.MarketMedian))
+		} else {
+			v = fmt.Sprintf("📈 %.0f%% above (median ¥%s)", -deal.BelowMarketPct, notify.FormatPrice(deal.MarketMedian))
+		}
+		e.Fields = append(e.Fields, embedField{Name: "Market", Value: v, Inline: true})
+	}
+	if deal.BrandName != "" {
+		e.Fields = append(e.Fields, embedField{Name: "Brand", Value: deal.BrandName, Inline: true})
+	}
+	if deal.Condition != "" {
+		e.Fields = append(e.Fields, embedField{Name: "Condition", Value: deal.Condition, Inline: true})
+	}
+	if deal.Size != "" {
+		e.Fields = append(e.Fields, embedField{Name: "Size", Value: deal.Size, Inline: true})
+	}
+	if deal.Seller != "" {
+		seller := deal.Seller
+		if deal.SellerStars > 0 {
+			seller += " " + strings.Repeat("★", deal.SellerStars)
+		}
+		if deal.SellerRatings > 0 {
+			seller += fmt.Sprintf(" (%d reviews)", deal.SellerRatings)
+		}
+		e.Fields = append(e.Fields, embedField{Name: "Seller", Value: seller, Inline: true})
+	}
+	e.Fields = append(e.Fields, embedField{Name: "Posted", Value: fmt.Sprintf("%.0f min ago", deal.AgeMin), Inline: true})
+
+	return e
+}
@@ -0,0 +1,93 @@
+package discord_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/xuhoa/autobot/pkg/discord"
+	"github.com/xuhoa/autobot/pkg/notify"
+)
+
+// fakeWebhook records posted messages; the first `limited` posts get a 429.
+type fakeWebhook struct {
+	*httptest.Server
+	mu       sync.Mutex
+	limited  int
+	attempts int
+	messages []map[string]interface{}
+}
+
+func newFakeWebhook(limited int) *fakeWebhook {
+	f := &fakeWebhook{limited: limited}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.attempts++
+		if f.limited > 0 {
+			f.limited--
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"message":"You are being rate limited.","retry_after":0.05,"global":false}`))
+			return
+		}
+		var msg map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		f.messages = append(f.messages, msg)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return f
+}
+
+func TestSendDealEmbed(t *testing.T) {
+	hook := newFakeWebhook(0)
+	defer hook.Close()
+
+	n := discord.NewNotifier(hook.URL)
+	err := n.SendDeal(context.Background(), notify.DealItem{
+		Name: "UNDERCOVER scab jacket", Price: 12000, BrandName: "Undercover",
+		ImageURL: "https://static.example/m1.jpg", ItemURL: "https://jp.mercari.com/item/m1",
+		AgeMin: 4, MarketMedian: 20000, BelowMarketPct: 40,
+	})
+	if err != nil {
+		t.Fatalf("SendDeal: %v", err)
+	}
+	if len(hook.messages) != 1 {
+		t.Fatalf("got %d posts, want 1", len(hook.messages))
+	}
+
+	raw, _ := json.Marshal(hook.messages[0]["embeds"])
+	embeds := string(raw)
+	for _, want := range []string{
+		`"url":"https://jp.mercari.com/item/m1"`,
+		`"image":{"url":"https://static.example/m1.jpg"}`,
+		`¥12,000`, `"value":"Undercover"`, `40% below (median ¥20,000)`,
+	} {
+		if !strings.Contains(embeds, want) {
+			t.Errorf("embed lacks %s: %s", want, embeds)
+		}
+	}
+}
+
+func TestRetriesAfterRateLimit(t *testing.T) {
+	hook := newFakeWebhook(1)
+	defer hook.Close()
+
+	n := discord.NewNotifier(hook.URL)
+	if err := n.SendError(context.Background(), "boom"); err != nil {
+		t.Fatalf("SendError: %v", err)
+	}
+	hook.mu.Lock()
+	if hook.attempts != 2 || len(hook.messages) != 1 {
+		t.Errorf("attempts=%d delivered=%d, want 2 and 1", hook.attempts, len(hook.messages))
+	}
+	hook.limited = 2
+	hook.mu.Unlock()
+
+	if err := n.SendError(context.Background(), "boom"); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("got err %v after repeated 429s, want HTTP 429 error", err)
+	}
+}
@@ -0,0 +1,86 @@
+package mercari
+
+import (
+	"fmt"
+	"strings"
+)
+
+// conditionIDs maps readable item conditions to Mercari itemConditionId.
+var conditionIDs = map[string]int{
+	"new":      1, // 新品、未使用
+	"like_new": 2, // 未使用に近い
+	"good":     3, // 目立った傷や汚れなし
+	"fair":     4, // やや傷や汚れあり
+	"poor":     5, // 傷や汚れあり
+	"bad":      6, // 全体的に状態が悪い
+}
+
+// sizeIDs maps clothing sizes (洋服 size group) to Mercari sizeId.
+// Shoe and other size groups use different IDs; pass those as raw size_ids.
+var sizeIDs = map[string]int{
+	"xxs":       1,
+	"xs":        2,
+	"ss":        2,
+	"s":         3,
+	"m":         4,
+	"l":         5,
+	"xl":        6,
+	"ll":        6,
+	"xxl":       7,
+	"2xl":       7,
+	"3l":        7,
+	"3xl":       8,
+	"4l":        8,
+	"4xl":       9,
+	"5l":        9,
+	"free":      10,
+	"free_size": 10,
+}
+
+// shippingPayerIDs maps who pays shipping to Mercari shippingPayerId.
+var shippingPayerIDs = map[string][]int{
+	"":       nil,
+	"any":    nil,
+	"buyer":  {1}, // 着払い(購入者負担)
+	"seller": {2}, // 送料込み(出品者負担)
+}
+
+// ConditionIDs resolves condition names ("new", "like_new", "good", "fair",
+// "poor", "bad") to Mercari IDs.
+func ConditionIDs(names []string) ([]int, error) {
+	return lookupIDs("condition", names, conditionIDs)
+}
+
+// SizeIDs resolves clothing size names ("S", "M", "XL", "FREE", ...) to
+// Mercari IDs.
+func SizeIDs(names []string) ([]int, error) {
+	return lookupIDs("size", names, sizeIDs)
+}
+
+// ShippingPayerIDs resolves a shipping mode ("seller", "buyer" or "any")
+// to Mercari IDs. "any" and "" mean no filter.
+func ShippingPayerIDs(mode string) ([]int, error) {
+	ids, ok := shippingPayerIDs[strings.ToLower(strings.TrimSpace(mode))]
+	if !ok {
+		return nil, fmt.Errorf("unknown shipping mode %q (want seller, buyer or any)", mode)
+	}
+	return ids, nil
+}
+
+func lookupIDs(kind string, names []string, table map[string]int) ([]int, error) {
+	var ids []int
+	seen := make(map[int]bool)
+	for _, name := range names {
+		key := strings.ToLower(strings.TrimSpace(name))
+		key = strings.ReplaceAll(key, " ", "_")
+		id, ok := table[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown %s %q", kind, name)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
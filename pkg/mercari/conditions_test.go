@@ -0,0 +1,58 @@
+package mercari_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/xuhoa/autobot/pkg/mercari"
+	"github.com/xuhoa/autobot/pkg/mercari/mercaritest"
+)
+
+func TestFilterIDMapping(t *testing.T) {
+	conds, err := mercari.ConditionIDs([]string{"new", "Like New", "new"})
+	if err != nil || !reflect.DeepEqual(conds, []int{1, 2}) {
+		t.Errorf("ConditionIDs = %v, %v", conds, err)
+	}
+	sizes, err := mercari.SizeIDs([]string{"M", "l", "FREE"})
+	if err != nil || !reflect.DeepEqual(sizes, []int{4, 5, 10}) {
+		t.Errorf("SizeIDs = %v, %v", sizes, err)
+	}
+	ship, err := mercari.ShippingPayerIDs("seller")
+	if err != nil || !reflect.DeepEqual(ship, []int{2}) {
+		t.Errorf("ShippingPayerIDs = %v, %v", ship, err)
+	}
+	if ids, err := mercari.ShippingPayerIDs("any"); err != nil || ids != nil {
+		t.Errorf("any shipping = %v, %v; want no filter", ids, err)
+	}
+
+	if _, err := mercari.ConditionIDs([]string{"mint"}); err == nil {
+		t.Error("unknown condition accepted")
+	}
+	if _, err := mercari.ShippingPayerIDs("free"); err == nil {
+		t.Error("unknown shipping mode accepted")
+	}
+}
+
+func TestSearchSendsListingFilters(t *testing.T) {
+	srv := mercaritest.NewServer()
+	defer srv.Close()
+
+	s := newTestScanner(t, srv)
+	_, err := s.Search(context.Background(), mercari.SearchOptions{
+		Keyword:          "Yohji",
+		ItemConditionIDs: []int{1, 2},
+		SizeIDs:          []int{4},
+		ColorIDs:         []int{1},
+		ShippingPayerIDs: []int{2},
+		Limit:            10,
+	})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	cond := srv.Searches()[0].SearchCondition
+	if !reflect.DeepEqual(cond.ItemConditionID, []int{1, 2}) || !reflect.DeepEqual(cond.SizeID, []int{4}) ||
+		!reflect.DeepEqual(cond.ColorID, []int{1}) || !reflect.DeepEqual(cond.ShippingPayerID, []int{2}) {
+		t.Errorf("filters not sent: %+v", cond)
+	}
+}
@@ -0,0 +1,124 @@
+package mercari
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pemCreatedHeader records when a stored DPoP key was generated.
+const pemCreatedHeader = "Created"
+
+// LoadOrCreateKey loads the DPoP key pair stored at path (PEM, SEC 1).
+// If the file does not exist, or the key is older than rotateAfter
+// (0 = never rotate), a new key is generated and saved in its place.
+//
+// Reusing the key makes the client look like one long-lived browser
+// session instead of a fresh device on every restart.
+func LoadOrCreateKey(path string, rotateAfter time.Duration) (*ecdsa.PrivateKey, error) {
+	key, created, err := readKey(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("[SCANNER] No DPoP key at %s, generating a new one", path)
+		return RotateKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if rotateAfter > 0 && !created.IsZero() && time.Since(created) > rotateAfter {
+		log.Printf("[SCANNER] DPoP key is %s old (rotation every %s), rotating",
+			time.Since(created).Round(time.Hour), rotateAfter)
+		return RotateKey(path)
+	}
+
+	return key, nil
+}
+
+// RotateKey generates a fresh ECDSA P-256 key pair and atomically replaces
+// the key stored at path.
+func RotateKey(path string) (*ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating ECDSA key: %w", err)
+	}
+	if err := writeKey(path, key, time.Now()); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// readKey parses a PEM-encoded EC private key and its creation time.
+func readKey(path string) (*ecdsa.PrivateKey, time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "EC PRIVATE KEY" {
+		return nil, time.Time{}, fmt.Errorf("%s: no EC PRIVATE KEY block found", path)
+	}
+
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("%s: parsing key: %w", path, err)
+	}
+	if key.Curve != elliptic.P256() {
+		return nil, time.Time{}, fmt.Errorf("%s: key is not P-256", path)
+	}
+
+	// Fall back to the file's mtime for keys without a Created header
+	created, err := time.Parse(time.RFC3339, block.Headers[pemCreatedHeader])
+	if err != nil {
+		if info, statErr := os.Stat(path); statErr == nil {
+			created = info.ModTime()
+		}
+	}
+
+	return key, created, nil
+}
+
+// writeKey stores key at path with owner-only permissions.
+func writeKey(path string, key *ecdsa.PrivateKey, created time.Time) error {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("encoding key: %w", err)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:    "EC PRIVATE KEY",
+		Headers: map[string]string{pemCreatedHeader: created.UTC().Format(time.RFC3339)},
+		Bytes:   der,
+	})
+
+	// Write to a temp file first so a crash never leaves a half-written key
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".dpop-*.pem")
+	if err != nil {
+		return fmt.Errorf("creating temp key file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("chmod key file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing key file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing key file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("saving key file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package mercari_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/mercari"
+)
+
+func TestLoadOrCreateKeyPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autobot_dpop.pem")
+
+	first, err := mercari.LoadOrCreateKey(path, 0)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("key not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("key file mode %o, want 600", perm)
+	}
+
+	second, err := mercari.LoadOrCreateKey(path, 0)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !first.Equal(second) {
+		t.Error("reloaded key differs from the stored one")
+	}
+
+	rotated, err := mercari.RotateKey(path)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if rotated.Equal(first) {
+		t.Error("RotateKey kept the old key")
+	}
+}
+
+func TestLoadOrCreateKeyRotatesOldKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autobot_dpop.pem")
+
+	first, err := mercari.LoadOrCreateKey(path, time.Hour)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	again, err := mercari.LoadOrCreateKey(path, time.Hour)
+	if err != nil || !again.Equal(first) {
+		t.Fatalf("fresh key was rotated (err %v)", err)
+	}
+
+	// Created is stored with second precision, so any key is older than 1ms
+	time.Sleep(10 * time.Millisecond)
+	rotated, err := mercari.LoadOrCreateKey(path, time.Millisecond)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if rotated.Equal(first) {
+		t.Error("key older than the rotation period was kept")
+	}
+}
@@ -0,0 +1,87 @@
+package mercari
+
+import (
+	"log"
+	"strings"
+)
+
+// ExcludeByKeywords drops items whose name contains any of the exclude
+// keywords (case-insensitive). Mercari applies the single-word ones
+// server-side via excludeKeyword, but its matching is fuzzy and phrases
+// cannot be sent, so this is the safety net and the only phrase check.
+func ExcludeByKeywords(items []Item, excludes []string) []Item {
+	if len(excludes) == 0 {
+		return items
+	}
+
+	kept := make([]Item, 0, len(items))
+	for _, item := range items {
+		if kw := matchExclude(item.Name, excludes); kw != "" {
+			log.Printf("[FILTER] 🚫 EXCLUDE: '%s' (matched '%s')", item.Name, kw)
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+// ServerExcludes builds Mercari's excludeKeyword for a search. Mercari
+// splits it on spaces, so a phrase like "CDG PLAY" would exclude "CDG" on
+// its own and drop the brand's results; only single-word excludes that are
+// not part of the search keyword are sent. ExcludeByKeywords checks the rest.
+func ServerExcludes(keyword string, excludes []string) string {
+	inKeyword := make(map[string]bool)
+	for _, w := range strings.Fields(strings.ToLower(keyword)) {
+		inKeyword[w] = true
+	}
+
+	var words []string
+	for _, kw := range excludes {
+		f := strings.Fields(kw)
+		if len(f) != 1 || inKeyword[strings.ToLower(f[0])] {
+			continue
+		}
+		words = append(words, f[0])
+	}
+	return strings.Join(words, " ")
+}
+
+// matchExclude returns the first exclude keyword found in name, or "".
+func matchExclude(name string, excludes []string) string {
+	lower := strings.ToLower(name)
+	for _, kw := range excludes {
+		kw = strings.TrimSpace(kw)
+		if kw != "" && strings.Contains(lower, strings.ToLower(kw)) {
+			return kw
+		}
+	}
+	return ""
+}
+
+// FilterByBrandIDs keeps only items whose Mercari brand is one of ids.
+// Keyword searches also match listings that merely mention a brand; this
+// drops them once a brand's canonical IDs are known.
+func FilterByBrandIDs(items []Item, ids []int) []Item {
+	if len(ids) == 0 {
+		return items
+	}
+
+	kept := make([]Item, 0, len(items))
+	for _, item := range items {
+		if !containsInt(ids, item.BrandID) {
+			log.Printf("[FILTER] 🚫 BRAND: '%s' (brand %d '%s' not in %v)", item.Name, item.BrandID, item.BrandName, ids)
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
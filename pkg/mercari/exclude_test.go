@@ -0,0 +1,64 @@
+package mercari_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xuhoa/autobot/pkg/mercari"
+	"github.com/xuhoa/autobot/pkg/mercari/mercaritest"
+)
+
+func TestExcludeByKeywords(t *testing.T) {
+	items := []mercari.Item{
+		{ID: "1", Name: "COMME des GARCONS Homme jacket"},
+		{ID: "2", Name: "cdg play ハートロゴ Tシャツ"},
+		{ID: "3", Name: "UNDERCOVER iPhoneケース"},
+	}
+
+	kept := mercari.ExcludeByKeywords(items, []string{"CDG PLAY", " iphoneケース "})
+	if len(kept) != 1 || kept[0].ID != "1" {
+		t.Errorf("kept %+v, want only item 1", kept)
+	}
+	if got := mercari.ExcludeByKeywords(items, nil); len(got) != 3 {
+		t.Errorf("nil excludes dropped items: %d left", len(got))
+	}
+}
+
+func TestSearchSendsExcludeKeyword(t *testing.T) {
+	srv := mercaritest.NewServer(fixtureItems(3)...)
+	defer srv.Close()
+
+	s := newTestScanner(t, srv)
+	if _, err := s.Search(context.Background(), mercari.SearchOptions{Keyword: "CDG", ExcludeKeywords: []string{"PLAY", "スマホケース"}, Limit: 10}); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if got := srv.Searches()[0].SearchCondition.ExcludeKeyword; got != "PLAY スマホケース" {
+		t.Errorf("excludeKeyword = %q", got)
+	}
+}
+
+func TestSearchKeepsPhraseExcludesClientSide(t *testing.T) {
+	srv := mercaritest.NewServer(
+		mercari.Item{ID: "m1", Name: "CDG jacket", Price: 9000},
+		mercari.Item{ID: "m2", Name: "CDG PLAY tee", Price: 5000},
+	)
+	defer srv.Close()
+
+	// "CDG PLAY" shares "CDG" with the keyword: sending it would exclude
+	// every result, so only the single-word exclude goes to Mercari
+	excludes := []string{"CDG PLAY", "スマホケース", "cdg"}
+	s := newTestScanner(t, srv)
+	items, err := s.Search(context.Background(), mercari.SearchOptions{Keyword: "CDG", ExcludeKeywords: excludes, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if got := srv.Searches()[0].SearchCondition.ExcludeKeyword; got != "スマホケース" {
+		t.Errorf("excludeKeyword = %q, want only the single word", got)
+	}
+	if len(items) != 2 {
+		t.Fatalf("search returned %d items, want both CDG listings", len(items))
+	}
+	if kept := mercari.ExcludeByKeywords(items, excludes[:2]); len(kept) != 1 || kept[0].ID != "m1" {
+		t.Errorf("client-side exclude kept %+v, want only m1", kept)
+	}
+}
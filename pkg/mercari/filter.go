@@ -7,6 +7,7 @@ package mercari
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,6 +15,8 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/xuhoa/autobot/pkg/clock"
 )
 
 // AIFilter uses HuggingFace CLIP to classify item images.
@@ -89,9 +92,13 @@ type clipResponse struct {
 
 // FilterItems runs AI classification on items and removes trash.
 // It processes images concurrently with a limited goroutine pool (RPi-safe).
-func (f *AIFilter) FilterItems(items []Item) []Item {
+// If ctx is cancelled, unclassified items are kept (fail-open).
+func (f *AIFilter) FilterItems(ctx context.Context, items []Item) []Item {
 	if !f.enabled {
 		log.Println("[FILTER] AI filter disabled, passing all items through")
+		for i := range items {
+			items[i].FilterLabel = "disabled"
+		}
 		return items
 	}
 
@@ -120,14 +127,20 @@ func (f *AIFilter) FilterItems(items []Item) []Item {
 			continue
 		}
 
+		// acquire slot
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i] = result{index: i, keep: true, label: "cancelled", score: 0}
+			continue
+		}
 		wg.Add(1)
-		sem <- struct{}{} // acquire slot
 
 		go func(idx int, it Item) {
 			defer wg.Done()
 			defer func() { <-sem }() // release slot
 
-			keep, label, score := f.classifyItem(it)
+			keep, label, score := f.classifyItem(ctx, it, true)
 			results[idx] = result{index: idx, keep: keep, label: label, score: score}
 		}(i, item)
 	}
@@ -137,6 +150,7 @@ func (f *AIFilter) FilterItems(items []Item) []Item {
 	// Collect kept items
 	kept := make([]Item, 0)
 	for i, r := range results {
+		items[i].FilterLabel, items[i].FilterScore = r.label, r.score
 		if r.keep {
 			kept = append(kept, items[i])
 			log.Printf("[FILTER] ✅ KEEP: '%s' (label='%s' score=%.2f)", items[i].Name, r.label, r.score)
@@ -150,8 +164,9 @@ func (f *AIFilter) FilterItems(items []Item) []Item {
 }
 
 // classifyItem checks a single item's first image using CLIP.
-// Returns (keep, topLabel, topScore).
-func (f *AIFilter) classifyItem(item Item) (bool, string, float64) {
+// Returns (keep, topLabel, topScore). If retryLoading is set and the model
+// is still loading, it waits and retries once.
+func (f *AIFilter) classifyItem(ctx context.Context, item Item, retryLoading bool) (bool, string, float64) {
 	if len(item.ImageURLs) == 0 {
 		return true, "no_image", 0
 	}
@@ -175,7 +190,7 @@ func (f *AIFilter) classifyItem(item Item) (bool, string, float64) {
 	}
 
 	apiURL := fmt.Sprintf("https://router.huggingface.co/hf-inference/models/%s", f.model)
-	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(jsonBody))
 	if err != nil {
 		log.Printf("[FILTER] Error creating request: %v", err)
 		return true, "error", 0
@@ -200,10 +215,12 @@ func (f *AIFilter) classifyItem(item Item) (bool, string, float64) {
 	if resp.StatusCode != http.StatusOK {
 		log.Printf("[FILTER] HuggingFace API returned %d: %s", resp.StatusCode, string(body[:min(len(body), 200)]))
 		// If model is loading, wait and retry once
-		if resp.StatusCode == 503 {
+		if resp.StatusCode == 503 && retryLoading {
 			log.Println("[FILTER] Model is loading, waiting 20s and retrying...")
-			time.Sleep(20 * time.Second)
-			return f.classifyItem(item) // retry once
+			if err := clock.Sleep(ctx, 20*time.Second); err != nil {
+				return true, "cancelled", 0
+			}
+			return f.classifyItem(ctx, item, false) // retry once
 		}
 		return true, "api_error", 0 // fail-open
 	}
@@ -0,0 +1,194 @@
+package mercari
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+// Listing statuses in item details (Item.Status as set by GetItem and
+// EnrichItems).
+const (
+	ItemOnSale    = "on_sale"
+	ItemTrading   = "trading" // bought, the sale is being completed
+	ItemSoldOut   = "sold_out"
+	ItemStopped   = "stop"   // taken off sale by the seller, may come back
+	ItemCancelled = "cancel" // withdrawn for good
+)
+
+// itemAPIResponse is the response of Mercari's item-detail endpoint.
+type itemAPIResponse struct {
+	Result string        `json:"result"`
+	Data   itemAPIDetail `json:"data"`
+}
+
+type itemAPIDetail struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Price       json.Number `json:"price"`
+	Status      string      `json:"status"`
+	Description string      `json:"description"`
+	Photos      []string    `json:"photos"`
+	Thumbnails  []string    `json:"thumbnails"`
+	Created     json.Number `json:"created"`
+	Updated     json.Number `json:"updated"`
+	Seller      *struct {
+		ID              json.Number `json:"id"`
+		Name            string      `json:"name"`
+		NumRatings      json.Number `json:"num_ratings"`
+		StarRatingScore json.Number `json:"star_rating_score"`
+	} `json:"seller"`
+	ItemCategory *struct {
+		ID   json.Number `json:"id"`
+		Name string      `json:"name"`
+	} `json:"item_category"`
+	ItemCondition *struct {
+		ID   json.Number `json:"id"`
+		Name string      `json:"name"`
+	} `json:"item_condition"`
+	ItemSize *struct {
+		ID   json.Number `json:"id"`
+		Name string      `json:"name"`
+	} `json:"item_size"`
+	ItemBrand *struct {
+		ID   json.Number `json:"id"`
+		Name string      `json:"name"`
+	} `json:"item_brand"`
+}
+
+// GetItem fetches the full listing for an item ID: description, all photos,
+// seller, condition, size and category. The search endpoint only returns
+// thumbnails, so this is used to enrich items before alerting.
+func (s *Scanner) GetItem(ctx context.Context, id string) (*Item, error) {
+	reqURL := s.itemURL + "?id=" + url.QueryEscape(id)
+
+	body, err := s.doAPIRequest(ctx, "GET", reqURL, s.itemURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("item request failed for %s: %w", id, err)
+	}
+
+	var apiResp itemAPIResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("parsing item response: %w", err)
+	}
+	if apiResp.Result != "" && apiResp.Result != "OK" {
+		return nil, fmt.Errorf("mercari item API result %q for %s", apiResp.Result, id)
+	}
+
+	item := apiResp.Data.toItem()
+	if item.ID == "" {
+		item.ID = id
+		item.ItemURL = "https://jp.mercari.com/item/" + id
+	}
+	return &item, nil
+}
+
+// EnrichItems replaces search results with their full details where possible.
+// Items whose detail request fails are kept as-is (fail-open).
+func (s *Scanner) EnrichItems(ctx context.Context, items []Item) []Item {
+	enriched := make([]Item, 0, len(items))
+	for _, item := range items {
+		if ctx.Err() != nil {
+			enriched = append(enriched, item)
+			continue
+		}
+		detail, err := s.GetItem(ctx, item.ID)
+		if err != nil {
+			log.Printf("[SCANNER] ⚠️ Could not fetch details for %s: %v", item.ID, err)
+			enriched = append(enriched, item)
+			continue
+		}
+		enriched = append(enriched, mergeItem(item, *detail))
+	}
+	return enriched
+}
+
+// toItem converts an item-detail response into our Item type.
+func (d *itemAPIDetail) toItem() Item {
+	images := d.Photos
+	if len(images) == 0 {
+		images = d.Thumbnails
+	}
+
+	item := Item{
+		ID:          d.ID,
+		Name:        d.Name,
+		Price:       jsonNumberToInt(d.Price),
+		Status:      d.Status,
+		Description: d.Description,
+		ImageURLs:   images,
+		Created:     time.Unix(jsonNumberToInt64(d.Created), 0),
+		Updated:     time.Unix(jsonNumberToInt64(d.Updated), 0),
+		ItemURL:     "https://jp.mercari.com/item/" + d.ID,
+	}
+	if d.Seller != nil {
+		item.Seller = d.Seller.Name
+		item.SellerID = d.Seller.ID.String()
+		item.SellerStars = jsonNumberToInt(d.Seller.StarRatingScore)
+		item.SellerRatings = jsonNumberToInt(d.Seller.NumRatings)
+	}
+	if d.ItemCategory != nil {
+		item.CategoryID = jsonNumberToInt(d.ItemCategory.ID)
+	}
+	if d.ItemCondition != nil {
+		item.Condition = d.ItemCondition.Name
+	}
+	if d.ItemSize != nil {
+		item.Size = d.ItemSize.Name
+	}
+	if d.ItemBrand != nil {
+		item.BrandName = d.ItemBrand.Name
+		item.BrandID = jsonNumberToInt(d.ItemBrand.ID)
+	}
+	return item
+}
+
+// mergeItem overlays non-empty detail fields onto a search result.
+func mergeItem(base, detail Item) Item {
+	if detail.Name != "" {
+		base.Name = detail.Name
+	}
+	if detail.Price > 0 {
+		base.Price = detail.Price
+	}
+	if detail.Status != "" {
+		base.Status = detail.Status
+	}
+	if detail.Description != "" {
+		base.Description = detail.Description
+	}
+	if len(detail.ImageURLs) > 0 {
+		base.ImageURLs = detail.ImageURLs
+	}
+	if detail.Seller != "" {
+		base.Seller = detail.Seller
+	}
+	if detail.SellerID != "" {
+		base.SellerID = detail.SellerID
+	}
+	if detail.SellerStars > 0 {
+		base.SellerStars = detail.SellerStars
+	}
+	if detail.SellerRatings > 0 {
+		base.SellerRatings = detail.SellerRatings
+	}
+	if detail.Condition != "" {
+		base.Condition = detail.Condition
+	}
+	if detail.Size != "" {
+		base.Size = detail.Size
+	}
+	if detail.CategoryID > 0 {
+		base.CategoryID = detail.CategoryID
+	}
+	if detail.BrandName != "" {
+		base.BrandName = detail.BrandName
+	}
+	if detail.BrandID > 0 {
+		base.BrandID = detail.BrandID
+	}
+	return base
+}
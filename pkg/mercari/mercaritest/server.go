@@ -0,0 +1,524 @@
+// Package mercaritest provides an in-process fake of the Mercari API for tests.
+//
+// The fake serves the v2 search and item-detail endpoints from fixture
+// items, checks every request's DPoP proof (ES256 signature, htu, htm, iat)
+// and can be told to fail upcoming requests with 403/429/500 responses.
+// Point a scanner at it with mercari.WithBaseURL(srv.URL).
+package mercaritest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/mercari"
+)
+
+// SearchRequest is the part of a search request body the fake understands.
+// Every decoded request is recorded for assertions.
+type SearchRequest struct {
+	PageSize        int    `json:"pageSize"`
+	PageToken       string `json:"pageToken"`
+	SearchSessionID string `json:"searchSessionId"`
+	SearchCondition struct {
+		Keyword         string   `json:"keyword"`
+		ExcludeKeyword  string   `json:"excludeKeyword"`
+		Status          []string `json:"status"`
+		CategoryID      []int    `json:"categoryId"`
+		BrandID         []int    `json:"brandId"`
+		SellerID        []string `json:"sellerId"`
+		PriceMin        int      `json:"priceMin"`
+		PriceMax        int      `json:"priceMax"`
+		ItemConditionID []int    `json:"itemConditionId"`
+		SizeID          []int    `json:"sizeId"`
+		ColorID         []int    `json:"colorId"`
+		ShippingPayerID []int    `json:"shippingPayerId"`
+	} `json:"searchCondition"`
+}
+
+// Server is a fake Mercari API backed by fixture items.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	items    []mercari.Item
+	failures []int
+	searches []SearchRequest
+	itemGets []string
+	dpopErrs []error
+}
+
+// NewServer starts a fake Mercari API. Call Close when done.
+func NewServer(items ...mercari.Item) *Server {
+	s := &Server{}
+	s.SetItems(items...)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/entities:search", s.handleSearch)
+	mux.HandleFunc("/items/get", s.handleItem)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// SetItems replaces the fixture listings. Items with an empty Status are
+// treated as on sale.
+func (s *Server) SetItems(items ...mercari.Item) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = append([]mercari.Item(nil), items...)
+	sort.SliceStable(s.items, func(i, j int) bool {
+		return s.items[i].Created.After(s.items[j].Created)
+	})
+}
+
+// FailNext makes the next n requests (search or item) return status.
+// 429 responses carry a Retry-After header like the real API.
+func (s *Server) FailNext(status, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// Searches returns every search request received so far.
+func (s *Server) Searches() []SearchRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SearchRequest(nil), s.searches...)
+}
+
+// ItemRequests returns the IDs requested from the item-detail endpoint.
+func (s *Server) ItemRequests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.itemGets...)
+}
+
+// DPoPErrors returns the reasons any requests were rejected for a bad
+// DPoP proof. A correct client leaves this empty.
+func (s *Server) DPoPErrors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]error(nil), s.dpopErrs...)
+}
+
+// ---------- Handlers ----------
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if !s.checkRequest(w, r, http.MethodPost) {
+		return
+	}
+
+	var req SearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad search body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.searches = append(s.searches, req)
+	var matched []mercari.Item
+	for _, item := range s.items {
+		if matchesSearch(item, req) {
+			matched = append(matched, item)
+		}
+	}
+	s.mu.Unlock()
+
+	offset := 0
+	if req.PageToken != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(req.PageToken, "v1:"))
+		if err != nil || n < 0 {
+			http.Error(w, "bad page token", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 120
+	}
+
+	end := offset + pageSize
+	if end > len(matched) {
+		end = len(matched)
+	}
+	if offset > end {
+		offset = end
+	}
+
+	resp := searchResponse{Items: make([]searchItem, 0, end-offset)}
+	for _, item := range matched[offset:end] {
+		resp.Items = append(resp.Items, toSearchItem(item))
+	}
+	resp.Meta.NumFound = strconv.Itoa(len(matched))
+	if end < len(matched) {
+		resp.Meta.HasNext = true
+		resp.Meta.NextPageToken = "v1:" + strconv.Itoa(end)
+	}
+
+	writeJSON(w, resp)
+}
+
+func (s *Server) handleItem(w http.ResponseWriter, r *http.Request) {
+	if !s.checkRequest(w, r, http.MethodGet) {
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+
+	s.mu.Lock()
+	s.itemGets = append(s.itemGets, id)
+	var found *mercari.Item
+	for i := range s.items {
+		if s.items[i].ID == id {
+			item := s.items[i]
+			found = &item
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if found == nil {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]string{"result": "error", "message": "not found"})
+		return
+	}
+
+	writeJSON(w, itemResponse{Result: "OK", Data: toItemDetail(*found)})
+}
+
+// checkRequest applies queued failures and validates the DPoP proof.
+// It writes an error response and returns false if the request must stop.
+func (s *Server) checkRequest(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	s.mu.Lock()
+	var status int
+	if len(s.failures) > 0 {
+		status = s.failures[0]
+		s.failures = s.failures[1:]
+	}
+	s.mu.Unlock()
+
+	if status != 0 {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		http.Error(w, fmt.Sprintf(`{"code":%d,"message":"simulated failure"}`, status), status)
+		return false
+	}
+
+	htu := "http://" + r.Host + r.URL.Path
+	if err := VerifyDPoP(r.Header.Get("DPoP"), method, htu); err != nil {
+		s.mu.Lock()
+		s.dpopErrs = append(s.dpopErrs, err)
+		s.mu.Unlock()
+		http.Error(w, `{"code":16,"message":"invalid dpop"}`, http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// ---------- DPoP verification ----------
+
+// VerifyDPoP checks a DPoP proof JWT the way Mercari does: ES256 signature
+// over the embedded P-256 JWK, matching htm/htu and a recent iat.
+func VerifyDPoP(token, method, htu string) error {
+	if token == "" {
+		return fmt.Errorf("missing DPoP header")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("DPoP token has %d parts, want 3", len(parts))
+	}
+
+	var header struct {
+		Typ string `json:"typ"`
+		Alg string `json:"alg"`
+		JWK struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"jwk"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("DPoP header: %w", err)
+	}
+	if header.Typ != "dpop+jwt" || header.Alg != "ES256" {
+		return fmt.Errorf("DPoP header typ=%q alg=%q", header.Typ, header.Alg)
+	}
+	if header.JWK.Kty != "EC" || header.JWK.Crv != "P-256" {
+		return fmt.Errorf("DPoP jwk kty=%q crv=%q", header.JWK.Kty, header.JWK.Crv)
+	}
+
+	x, errX := base64.RawURLEncoding.DecodeString(header.JWK.X)
+	y, errY := base64.RawURLEncoding.DecodeString(header.JWK.Y)
+	if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+		return fmt.Errorf("DPoP jwk coordinates are not 32-byte base64url")
+	}
+	pub := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return fmt.Errorf("DPoP signature is not 64-byte base64url")
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(sig[:32])
+	ss := new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(pub, hash[:], r, ss) {
+		return fmt.Errorf("DPoP signature does not verify")
+	}
+
+	var payload struct {
+		IAT  int64  `json:"iat"`
+		JTI  string `json:"jti"`
+		HTU  string `json:"htu"`
+		HTM  string `json:"htm"`
+		UUID string `json:"uuid"`
+	}
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return fmt.Errorf("DPoP payload: %w", err)
+	}
+	if payload.HTM != method {
+		return fmt.Errorf("DPoP htm=%q, want %q", payload.HTM, method)
+	}
+	if payload.HTU != htu {
+		return fmt.Errorf("DPoP htu=%q, want %q", payload.HTU, htu)
+	}
+	if payload.JTI == "" {
+		return fmt.Errorf("DPoP jti is empty")
+	}
+	if age := time.Since(time.Unix(payload.IAT, 0)); age > time.Minute || age < -time.Minute {
+		return fmt.Errorf("DPoP iat is %s off", age.Round(time.Second))
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ---------- Fixture encoding ----------
+
+// searchResponse mirrors the v2 search response, numbers as strings.
+type searchResponse struct {
+	Items []searchItem `json:"items"`
+	Meta  struct {
+		NumFound      string `json:"numFound"`
+		NextPageToken string `json:"nextPageToken"`
+		HasNext       bool   `json:"hasNext"`
+	} `json:"meta"`
+}
+
+type searchItem struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Price      string   `json:"price"`
+	Status     string   `json:"status"`
+	Created    string   `json:"created"`
+	Updated    string   `json:"updated"`
+	Thumbnails []string `json:"thumbnails"`
+	ItemType   string   `json:"itemType"`
+	SellerID   string   `json:"sellerId"`
+	CategoryID string   `json:"categoryId,omitempty"`
+	ItemBrand  *brand   `json:"itemBrand,omitempty"`
+}
+
+type brand struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type itemResponse struct {
+	Result string     `json:"result"`
+	Data   itemDetail `json:"data"`
+}
+
+type itemDetail struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Price       int         `json:"price"`
+	Status      string      `json:"status"`
+	Description string      `json:"description"`
+	Photos      []string    `json:"photos"`
+	Created     int64       `json:"created"`
+	Updated     int64       `json:"updated"`
+	Seller      *seller     `json:"seller,omitempty"`
+	Category    *idName     `json:"item_category,omitempty"`
+	Condition   *idName     `json:"item_condition,omitempty"`
+	Size        *idName     `json:"item_size,omitempty"`
+	Brand       *idNameText `json:"item_brand,omitempty"`
+}
+
+type seller struct {
+	ID              json.Number `json:"id"`
+	Name            string      `json:"name"`
+	NumRatings      int         `json:"num_ratings"`
+	StarRatingScore int         `json:"star_rating_score"`
+}
+
+type idName struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type idNameText struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func toSearchItem(item mercari.Item) searchItem {
+	si := searchItem{
+		ID:         item.ID,
+		Name:       item.Name,
+		Price:      strconv.Itoa(item.Price),
+		Status:     "ITEM_STATUS_" + strings.ToUpper(itemStatus(item)),
+		Created:    strconv.FormatInt(item.Created.Unix(), 10),
+		Updated:    strconv.FormatInt(item.Updated.Unix(), 10),
+		Thumbnails: item.ImageURLs,
+		ItemType:   "ITEM_TYPE_MERCARI",
+		SellerID:   item.SellerID,
+	}
+	if item.CategoryID != 0 {
+		si.CategoryID = strconv.Itoa(item.CategoryID)
+	}
+	if item.BrandName != "" || item.BrandID != 0 {
+		si.ItemBrand = &brand{ID: strconv.Itoa(item.BrandID), Name: item.BrandName}
+	}
+	return si
+}
+
+func toItemDetail(item mercari.Item) itemDetail {
+	d := itemDetail{
+		ID:          item.ID,
+		Name:        item.Name,
+		Price:       item.Price,
+		Status:      itemStatus(item),
+		Description: item.Description,
+		Photos:      item.ImageURLs,
+		Created:     item.Created.Unix(),
+		Updated:     item.Updated.Unix(),
+	}
+	if item.SellerID != "" || item.Seller != "" {
+		d.Seller = &seller{
+			ID:              json.Number(item.SellerID),
+			Name:            item.Seller,
+			NumRatings:      item.SellerRatings,
+			StarRatingScore: item.SellerStars,
+		}
+	}
+	if item.CategoryID != 0 {
+		d.Category = &idName{ID: item.CategoryID}
+	}
+	if item.Condition != "" {
+		d.Condition = &idName{Name: item.Condition}
+	}
+	if item.Size != "" {
+		d.Size = &idName{Name: item.Size}
+	}
+	if item.BrandName != "" || item.BrandID != 0 {
+		d.Brand = &idNameText{ID: strconv.Itoa(item.BrandID), Name: item.BrandName}
+	}
+	return d
+}
+
+// matchesSearch applies the search conditions the fake supports.
+func matchesSearch(item mercari.Item, req SearchRequest) bool {
+	cond := req.SearchCondition
+
+	name := strings.ToLower(item.Name)
+	for _, term := range strings.Fields(strings.ToLower(cond.Keyword)) {
+		if !strings.Contains(name, term) {
+			return false
+		}
+	}
+	for _, term := range strings.Fields(strings.ToLower(cond.ExcludeKeyword)) {
+		if strings.Contains(name, term) {
+			return false
+		}
+	}
+	if cond.PriceMin > 0 && item.Price < cond.PriceMin {
+		return false
+	}
+	if cond.PriceMax > 0 && item.Price > cond.PriceMax {
+		return false
+	}
+	if len(cond.CategoryID) > 0 && item.CategoryID != 0 && !containsInt(cond.CategoryID, item.CategoryID) {
+		return false
+	}
+	if len(cond.BrandID) > 0 && !containsInt(cond.BrandID, item.BrandID) {
+		return false
+	}
+	if len(cond.SellerID) > 0 && !containsString(cond.SellerID, item.SellerID) {
+		return false
+	}
+	if len(cond.Status) > 0 {
+		want := false
+		for _, st := range cond.Status {
+			if strings.TrimPrefix(strings.ToLower(st), "status_") == itemStatus(item) {
+				want = true
+			}
+		}
+		if !want {
+			return false
+		}
+	}
+	return true
+}
+
+// itemStatus normalises a fixture status to on_sale / sold_out / trading.
+func itemStatus(item mercari.Item) string {
+	st := strings.TrimPrefix(strings.ToLower(item.Status), "item_status_")
+	if st == "" {
+		return "on_sale"
+	}
+	return st
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -5,18 +5,29 @@ import "time"
 
 // Item represents a single product listing on Mercari Japan.
 type Item struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Price       int       `json:"price"`        // JPY
-	Status      string    `json:"status"`        // on_sale, sold_out, etc.
-	Description string    `json:"description"`
-	ImageURLs   []string  `json:"image_urls"`
-	Seller      string    `json:"seller_name"`
-	Created     time.Time `json:"created"`
-	Updated     time.Time `json:"updated"`
-	CategoryID  int       `json:"category_id"`
-	BrandName   string    `json:"brand_name"`   // matched brand from our config
-	ItemURL     string    `json:"item_url"`      // full URL to item page
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Price         int       `json:"price"`  // JPY
+	Status        string    `json:"status"` // on_sale, sold_out, etc.
+	Description   string    `json:"description"`
+	ImageURLs     []string  `json:"image_urls"`
+	Seller        string    `json:"seller_name"`
+	SellerID      string    `json:"seller_id"`
+	SellerStars   int       `json:"seller_stars"`   // star rating score (1-5), 0 if unknown
+	SellerRatings int       `json:"seller_ratings"` // number of reviews received
+	Condition     string    `json:"condition"`      // e.g. 未使用に近い
+	Size          string    `json:"size"`
+	Created       time.Time `json:"created"`
+	Updated       time.Time `json:"updated"`
+	CategoryID    int       `json:"category_id"`
+	BrandName     string    `json:"brand_name"` // matched brand from our config
+	BrandID       int       `json:"brand_id"`   // Mercari itemBrand ID, 0 if none
+	ItemURL       string    `json:"item_url"`   // full URL to item page
+
+	// AI filter verdict, set by AIFilter.FilterItems on kept items
+	// (label "no_image", "error", ... when the item was not classified)
+	FilterLabel string  `json:"-"`
+	FilterScore float64 `json:"-"`
 }
 
 // AgeMinutes returns how many minutes ago this item was listed.
@@ -28,33 +39,33 @@ func (item *Item) AgeMinutes() float64 {
 
 // SearchResponse is the top-level response from Mercari's search API.
 type SearchResponse struct {
-	Items      []RawItem `json:"items"`
-	Meta       MetaInfo  `json:"meta"`
+	Items []RawItem `json:"items"`
+	Meta  MetaInfo  `json:"meta"`
 }
 
 // RawItem maps the JSON structure returned by Mercari search.
 type RawItem struct {
-	ID          string        `json:"id"`
-	Name        string        `json:"name"`
-	Price       int           `json:"price"`
-	Status      string        `json:"status"`
-	Thumbnails  []string      `json:"thumbnails"`
-	ImageURLs   []string      `json:"item_image_urls"`
-	Created     int64         `json:"created"`
-	Updated     int64         `json:"updated"`
-	SellerID    string        `json:"seller_id"`
-	SellerName  string        `json:"seller_name,omitempty"`
-	Description string        `json:"description,omitempty"`
-	CategoryID  int           `json:"category_id"`
-	BrandName   string        `json:"brand_name,omitempty"`
-	ItemCondID  int           `json:"item_condition_id"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Price       int      `json:"price"`
+	Status      string   `json:"status"`
+	Thumbnails  []string `json:"thumbnails"`
+	ImageURLs   []string `json:"item_image_urls"`
+	Created     int64    `json:"created"`
+	Updated     int64    `json:"updated"`
+	SellerID    string   `json:"seller_id"`
+	SellerName  string   `json:"seller_name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	CategoryID  int      `json:"category_id"`
+	BrandName   string   `json:"brand_name,omitempty"`
+	ItemCondID  int      `json:"item_condition_id"`
 }
 
 // MetaInfo contains pagination info.
 type MetaInfo struct {
-	NumFound    int    `json:"num_found"`
+	NumFound      int    `json:"num_found"`
 	NextPageToken string `json:"next_page_token,omitempty"`
-	HasNext     bool   `json:"has_next"`
+	HasNext       bool   `json:"has_next"`
 }
 
 // ToItem converts a RawItem from the API into our clean Item struct.
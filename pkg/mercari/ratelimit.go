@@ -0,0 +1,69 @@
+package mercari
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/clock"
+)
+
+// RateLimiter is a token bucket shared by every request a Scanner sends,
+// so concurrent brand scans still add up to a fixed, polite request rate.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64 // bucket size
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter allows rps requests per second on average, with bursts of
+// up to burst requests. The bucket starts full.
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until the caller may send one request, or ctx is done.
+// Callers are served in the order they arrive: each one reserves its token
+// up front and sleeps until that token is due. A caller whose ctx ends
+// first gives its token back.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil || l.rate <= 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	err := ctx.Err()
+	if delay > 0 {
+		err = clock.Sleep(ctx, delay)
+	}
+	if err != nil {
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+	}
+	return err
+}
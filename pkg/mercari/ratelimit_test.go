@@ -0,0 +1,84 @@
+package mercari_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/mercari"
+)
+
+func TestRateLimiterSpacesRequests(t *testing.T) {
+	l := mercari.NewRateLimiter(20, 2) // one token every 50ms after a burst of 2
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Wait(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	// 2 immediate + 4 × 50ms
+	elapsed := time.Since(start)
+	if elapsed < 190*time.Millisecond || elapsed > 400*time.Millisecond {
+		t.Errorf("6 requests took %v, want ~200ms", elapsed)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	var nilLimiter *mercari.RateLimiter
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		nilLimiter.Wait(context.Background())
+		mercari.NewRateLimiter(0, 1).Wait(context.Background())
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Error("disabled limiter blocked")
+	}
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	l := mercari.NewRateLimiter(0.1, 1) // second token is 10s away
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx); err == nil {
+		t.Fatal("Wait returned nil after ctx was cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait blocked %v after cancellation", elapsed)
+	}
+}
+
+func TestRateLimiterCancelledWaitsGiveTokensBack(t *testing.T) {
+	l := mercari.NewRateLimiter(10, 1) // one token every 100ms
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(ctx); err == nil {
+			t.Fatal("Wait returned nil for a cancelled ctx")
+		}
+	}
+
+	// Only the first caller's token is outstanding, not six
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("Wait after cancelled waits took %v, want ~100ms", elapsed)
+	}
+}
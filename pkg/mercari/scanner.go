@@ -7,6 +7,7 @@ package mercari
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -20,29 +21,98 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Listing statuses accepted by SearchOptions.Statuses.
 const (
-	searchAPIURL = "https://api.mercari.jp/v2/entities:search"
+	StatusOnSale  = "STATUS_ON_SALE"
+	StatusSoldOut = "STATUS_SOLD_OUT" // sold and in-trade listings
+)
+
+const (
+	// DefaultBaseURL is the production Mercari API host.
+	DefaultBaseURL = "https://api.mercari.jp"
+
+	searchAPIPath = "/v2/entities:search"
+	itemAPIPath   = "/items/get"
 )
 
 // Scanner searches Mercari Japan for items using the internal API.
 type Scanner struct {
 	client     *http.Client
+	keyMu      sync.RWMutex // guards privateKey, swapped by SetPrivateKey
 	privateKey *ecdsa.PrivateKey
 	userAgent  string
+
+	// Endpoints (overridable for tests)
+	searchURL string
+	itemURL   string
+
+	// Shared request rate limit (nil = unlimited)
+	limiter *RateLimiter
+
+	// Pagination limits for Search
+	maxPages int           // max pages followed per search
+	maxAge   time.Duration // stop paging once items get older than this (0 = no limit)
 }
 
-// NewScanner creates a new Mercari scanner with DPoP key pair.
-func NewScanner() *Scanner {
-	// Generate fresh ECDSA P-256 key pair for DPoP tokens
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		log.Fatalf("[SCANNER] Failed to generate ECDSA key: %v", err)
+// ScannerOption configures optional Scanner behaviour.
+type ScannerOption func(*Scanner)
+
+// WithMaxPages limits how many result pages a single Search follows.
+func WithMaxPages(n int) ScannerOption {
+	return func(s *Scanner) {
+		if n > 0 {
+			s.maxPages = n
+		}
+	}
+}
+
+// WithMaxAge stops pagination once a page reaches items older than d.
+func WithMaxAge(d time.Duration) ScannerOption {
+	return func(s *Scanner) {
+		s.maxAge = d
+	}
+}
+
+// WithBaseURL points the scanner at a different API host, e.g. a local
+// fake Mercari server in tests (see package mercaritest).
+func WithBaseURL(baseURL string) ScannerOption {
+	return func(s *Scanner) {
+		baseURL = strings.TrimRight(baseURL, "/")
+		s.searchURL = baseURL + searchAPIPath
+		s.itemURL = baseURL + itemAPIPath
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client.
+func WithHTTPClient(c *http.Client) ScannerOption {
+	return func(s *Scanner) {
+		s.client = c
+	}
+}
+
+// WithRateLimiter makes every API request wait for a token from l.
+// Share one limiter between all scans to cap the total request rate.
+func WithRateLimiter(l *RateLimiter) ScannerOption {
+	return func(s *Scanner) {
+		s.limiter = l
+	}
+}
+
+// WithPrivateKey signs DPoP tokens with a persisted key pair
+// (see LoadOrCreateKey) instead of a fresh one.
+func WithPrivateKey(key *ecdsa.PrivateKey) ScannerOption {
+	return func(s *Scanner) {
+		s.privateKey = key
 	}
+}
 
-	return &Scanner{
+// NewScanner creates a new Mercari scanner with DPoP key pair.
+func NewScanner(opts ...ScannerOption) (*Scanner, error) {
+	s := &Scanner{
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 			Transport: &http.Transport{
@@ -52,18 +122,34 @@ func NewScanner() *Scanner {
 				DisableCompression:  false,
 			},
 		},
-		privateKey: privateKey,
-		userAgent:  randomUserAgent(),
+		userAgent: randomUserAgent(),
+		searchURL: DefaultBaseURL + searchAPIPath,
+		itemURL:   DefaultBaseURL + itemAPIPath,
+		maxPages:  1,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.privateKey == nil {
+		// Generate fresh ECDSA P-256 key pair for DPoP tokens
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generating ECDSA key: %w", err)
+		}
+		s.privateKey = privateKey
 	}
+
+	return s, nil
 }
 
 // ---------- DPoP JWT Token Generation ----------
 
 // dpopHeader is the JWT header for DPoP tokens.
 type dpopHeader struct {
-	Typ string     `json:"typ"`
-	Alg string     `json:"alg"`
-	JWK dpopJWK    `json:"jwk"`
+	Typ string  `json:"typ"`
+	Alg string  `json:"alg"`
+	JWK dpopJWK `json:"jwk"`
 }
 
 // dpopJWK contains the public key in JWK format.
@@ -74,6 +160,20 @@ type dpopJWK struct {
 	Y   string `json:"y"`
 }
 
+// SetPrivateKey replaces the DPoP key pair, e.g. after a rotation.
+// Requests already signed keep the old key.
+func (s *Scanner) SetPrivateKey(key *ecdsa.PrivateKey) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	s.privateKey = key
+}
+
+func (s *Scanner) key() *ecdsa.PrivateKey {
+	s.keyMu.RLock()
+	defer s.keyMu.RUnlock()
+	return s.privateKey
+}
+
 // dpopPayload is the JWT payload for DPoP.
 type dpopPayload struct {
 	IAT  int64  `json:"iat"`
@@ -85,7 +185,8 @@ type dpopPayload struct {
 
 // generateDPoP creates a DPoP JWT token for the given URL and method.
 func (s *Scanner) generateDPoP(apiURL, method string) (string, error) {
-	pubKey := &s.privateKey.PublicKey
+	privateKey := s.key()
+	pubKey := &privateKey.PublicKey
 
 	// Encode public key coordinates as base64url (unpadded)
 	xBytes := pubKey.X.Bytes()
@@ -128,7 +229,7 @@ func (s *Scanner) generateDPoP(apiURL, method string) (string, error) {
 
 	// Sign with ECDSA P-256 SHA-256
 	hash := sha256.Sum256([]byte(signingInput))
-	r, ss, err := ecdsa.Sign(rand.Reader, s.privateKey, hash[:])
+	r, ss, err := ecdsa.Sign(rand.Reader, privateKey, hash[:])
 	if err != nil {
 		return "", fmt.Errorf("ecdsa sign: %w", err)
 	}
@@ -148,6 +249,7 @@ func (s *Scanner) generateDPoP(apiURL, method string) (string, error) {
 // searchRequest is the body for Mercari's v2 search API.
 type searchRequest struct {
 	PageSize           int             `json:"pageSize"`
+	PageToken          string          `json:"pageToken"`
 	SearchSessionID    string          `json:"searchSessionId"`
 	SearchCondition    searchCondition `json:"searchCondition"`
 	ServiceFrom        string          `json:"serviceFrom"`
@@ -191,43 +293,145 @@ type searchAPIResponse struct {
 }
 
 type searchAPIItem struct {
-	ID              string      `json:"id"`
-	Name            string      `json:"name"`
-	Price           json.Number `json:"price"`
-	Status          string      `json:"status"`
-	Created         json.Number `json:"created"`
-	Updated         json.Number `json:"updated"`
-	Thumbnails      []string    `json:"thumbnails"`
-	ItemType        string      `json:"itemType"`
-	BuyerID         string      `json:"buyerId"`
-	SellerID        string      `json:"sellerId"`
-	ItemBrand       *struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	Price      json.Number `json:"price"`
+	Status     string      `json:"status"`
+	Created    json.Number `json:"created"`
+	Updated    json.Number `json:"updated"`
+	Thumbnails []string    `json:"thumbnails"`
+	ItemType   string      `json:"itemType"`
+	BuyerID    string      `json:"buyerId"`
+	SellerID   string      `json:"sellerId"`
+	ItemBrand  *struct {
 		ID   json.Number `json:"id"`
 		Name string      `json:"name"`
 	} `json:"itemBrand"`
 	ItemConditionID json.Number `json:"itemConditionId"`
+	CategoryID      json.Number `json:"categoryId"`
 }
 
-// Search queries Mercari for items matching the given criteria.
-func (s *Scanner) Search(keyword string, priceMin, priceMax int, categories []int, limit int) ([]Item, error) {
-	// Generate DPoP token for this request
-	dpopToken, err := s.generateDPoP(searchAPIURL, "POST")
-	if err != nil {
-		return nil, fmt.Errorf("generating DPoP token: %w", err)
+// SearchOptions describes a single Mercari search. Zero values mean
+// "no filter" for every condition.
+type SearchOptions struct {
+	Keyword         string
+	ExcludeKeywords []string // single words sent as excludeKeyword; see ServerExcludes
+	PriceMin        int
+	PriceMax        int
+	CategoryIDs     []int
+	BrandIDs        []int    // Mercari brand IDs (see Item.BrandID)
+	SellerIDs       []string // only listings from these sellers
+
+	ItemConditionIDs []int // see ConditionIDs
+	SizeIDs          []int // see SizeIDs
+	ColorIDs         []int
+	ShippingPayerIDs []int // see ShippingPayerIDs
+
+	Statuses []string // default: StatusOnSale
+	AnyAge   bool     // ignore the scanner's max age when paging (e.g. sold listings)
+
+	Limit int // page size
+}
+
+// label names the search in logs: the keyword, or the brand/seller IDs for
+// keyword-less searches.
+func (o SearchOptions) label() string {
+	switch {
+	case o.Keyword != "":
+		return o.Keyword
+	case len(o.BrandIDs) > 0:
+		return fmt.Sprintf("brand:%v", o.BrandIDs)
+	case len(o.SellerIDs) > 0:
+		return fmt.Sprintf("seller:%v", o.SellerIDs)
+	}
+	return o.Keyword
+}
+
+// Search queries Mercari for items matching the given options.
+// Results are sorted newest first; Search follows meta.nextPageToken until
+// it reaches items older than the scanner's max age or its page cap, and
+// returns a single slice with duplicate IDs removed.
+func (s *Scanner) Search(ctx context.Context, opts SearchOptions) ([]Item, error) {
+	var (
+		items     []Item
+		seen      = make(map[string]bool)
+		pageToken string
+		numFound  int64
+		pages     int
+	)
+
+	// One search session ID for all pages, like the web app
+	sessionID := generateUUID()
+
+	for pages < s.maxPages {
+		page, err := s.searchPage(ctx, opts, sessionID, pageToken)
+		if err != nil {
+			if pages > 0 && ctx.Err() == nil {
+				// Keep what we already have rather than losing the whole search
+				log.Printf("[SCANNER] '%s': page %d failed, returning %d items: %v",
+					opts.label(), pages+1, len(items), err)
+				break
+			}
+			return nil, err
+		}
+		pages++
+		if pages == 1 {
+			numFound, _ = page.Meta.NumFound.Int64()
+		}
+
+		tooOld := false
+		for _, raw := range page.Items {
+			item := raw.toItem()
+			if !opts.AnyAge && s.maxAge > 0 && time.Since(item.Created) > s.maxAge {
+				tooOld = true
+				continue
+			}
+			if seen[item.ID] {
+				continue
+			}
+			seen[item.ID] = true
+			items = append(items, item)
+		}
+
+		if tooOld || !page.Meta.HasNext || page.Meta.NextPageToken == "" {
+			break
+		}
+		pageToken = page.Meta.NextPageToken
+	}
+
+	log.Printf("[SCANNER] '%s': API returned %d items over %d page(s) (total: %d)",
+		opts.label(), len(items), pages, numFound)
+
+	return items, nil
+}
+
+// searchPage fetches a single page of search results.
+func (s *Scanner) searchPage(ctx context.Context, opts SearchOptions, sessionID, pageToken string) (*searchAPIResponse, error) {
+	statuses := opts.Statuses
+	if len(statuses) == 0 {
+		statuses = []string{StatusOnSale}
 	}
 
 	// Build request body
 	reqBody := searchRequest{
-		PageSize:        limit,
-		SearchSessionID: generateUUID(),
+		PageSize:        opts.Limit,
+		PageToken:       pageToken,
+		SearchSessionID: sessionID,
 		SearchCondition: searchCondition{
-			Keyword:    keyword,
-			Sort:       "SORT_CREATED_TIME",
-			Order:      "ORDER_DESC",
-			Status:     []string{"STATUS_ON_SALE"},
-			CategoryID: categories,
-			PriceMin:   priceMin,
-			PriceMax:   priceMax,
+			Keyword:         opts.Keyword,
+			ExcludeKeyword:  ServerExcludes(opts.Keyword, opts.ExcludeKeywords),
+			Sort:            "SORT_CREATED_TIME",
+			Order:           "ORDER_DESC",
+			Status:          statuses,
+			CategoryID:      opts.CategoryIDs,
+			BrandID:         opts.BrandIDs,
+			SellerID:        opts.SellerIDs,
+			PriceMin:        opts.PriceMin,
+			PriceMax:        opts.PriceMax,
+			ItemConditionID: opts.ItemConditionIDs,
+			SizeID:          opts.SizeIDs,
+			ColorID:         opts.ColorIDs,
+			ShippingPayerID: opts.ShippingPayerIDs,
 		},
 		ServiceFrom:        "suruga",
 		WithItemBrand:      true,
@@ -242,13 +446,73 @@ func (s *Scanner) Search(keyword string, priceMin, priceMax int, categories []in
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", searchAPIURL, bytes.NewReader(bodyJSON))
+	body, err := s.doAPIRequest(ctx, "POST", s.searchURL, s.searchURL, bodyJSON)
+	if err != nil {
+		return nil, fmt.Errorf("search request failed: %w", err)
+	}
+
+	// Parse response
+	var apiResp searchAPIResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+
+	return &apiResp, nil
+}
+
+// toItem converts a search result into our Item type.
+func (raw *searchAPIItem) toItem() Item {
+	brandName, brandID := "", 0
+	if raw.ItemBrand != nil {
+		brandName = raw.ItemBrand.Name
+		brandID = jsonNumberToInt(raw.ItemBrand.ID)
+	}
+
+	return Item{
+		ID:         raw.ID,
+		Name:       raw.Name,
+		Price:      jsonNumberToInt(raw.Price),
+		Status:     raw.Status,
+		ImageURLs:  raw.Thumbnails,
+		SellerID:   raw.SellerID,
+		CategoryID: jsonNumberToInt(raw.CategoryID),
+		Created:    time.Unix(jsonNumberToInt64(raw.Created), 0),
+		Updated:    time.Unix(jsonNumberToInt64(raw.Updated), 0),
+		BrandName:  brandName,
+		BrandID:    brandID,
+		ItemURL:    "https://jp.mercari.com/item/" + raw.ID,
+	}
+}
+
+// doAPIRequest sends a DPoP-authenticated request to the Mercari API and
+// returns the response body. htu is the URL the DPoP proof is bound to
+// (the request URL without its query string).
+func (s *Scanner) doAPIRequest(ctx context.Context, method, reqURL, htu string, body []byte) ([]byte, error) {
+	// Wait for our turn first so the DPoP iat is fresh when sent
+	if err := s.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	// Generate DPoP token for this request
+	dpopToken, err := s.generateDPoP(htu, method)
+	if err != nil {
+		return nil, fmt.Errorf("generating DPoP token: %w", err)
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
 	// Set headers — DPoP is the critical auth header
-	req.Header.Set("Content-Type", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	req.Header.Set("Accept", "application/json, text/plain, */*")
 	req.Header.Set("DPoP", dpopToken)
 	req.Header.Set("X-Platform", "web")
@@ -259,65 +523,27 @@ func (s *Scanner) Search(keyword string, priceMin, priceMax int, categories []in
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("search request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("reading response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("mercari API returned %d: %s", resp.StatusCode, truncate(string(body), 300))
-	}
-
-	// Parse response
-	var apiResp searchAPIResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, fmt.Errorf("parsing response: %w", err)
-	}
-
-	// Convert to our Item type
-	items := make([]Item, 0, len(apiResp.Items))
-	for _, raw := range apiResp.Items {
-		brandName := ""
-		if raw.ItemBrand != nil {
-			brandName = raw.ItemBrand.Name
-		}
-
-		price := jsonNumberToInt(raw.Price)
-		createdTS := jsonNumberToInt64(raw.Created)
-		updatedTS := jsonNumberToInt64(raw.Updated)
-
-		created := time.Unix(createdTS, 0)
-		updated := time.Unix(updatedTS, 0)
-
-		items = append(items, Item{
-			ID:        raw.ID,
-			Name:      raw.Name,
-			Price:     price,
-			Status:    raw.Status,
-			ImageURLs: raw.Thumbnails,
-			Created:   created,
-			Updated:   updated,
-			BrandName: brandName,
-			ItemURL:   "https://jp.mercari.com/item/" + raw.ID,
-		})
+		return nil, fmt.Errorf("mercari API returned %d: %s", resp.StatusCode, truncate(string(respBody), 300))
 	}
 
-	numFound, _ := apiResp.Meta.NumFound.Int64()
-	log.Printf("[SCANNER] '%s': API returned %d items (total: %d)",
-		keyword, len(items), numFound)
-
-	return items, nil
+	return respBody, nil
 }
 
 // SearchWithFallback tries the API. On failure, logs and returns error.
-func (s *Scanner) SearchWithFallback(keyword string, priceMin, priceMax int, categories []int, limit int) ([]Item, error) {
-	items, err := s.Search(keyword, priceMin, priceMax, categories, limit)
+func (s *Scanner) SearchWithFallback(ctx context.Context, opts SearchOptions) ([]Item, error) {
+	items, err := s.Search(ctx, opts)
 	if err != nil {
-		return nil, fmt.Errorf("search failed for '%s': %w", keyword, err)
+		return nil, fmt.Errorf("search failed for '%s': %w", opts.label(), err)
 	}
 	return items, nil
 }
@@ -0,0 +1,176 @@
+package mercari_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/mercari"
+	"github.com/xuhoa/autobot/pkg/mercari/mercaritest"
+)
+
+// fixtureItems returns n listings created one minute apart, newest first.
+func fixtureItems(n int) []mercari.Item {
+	now := time.Now()
+	items := make([]mercari.Item, n)
+	for i := range items {
+		items[i] = mercari.Item{
+			ID:        fmt.Sprintf("m%05d", i),
+			Name:      fmt.Sprintf("UNDERCOVER jacket %d", i),
+			Price:     5000 + i*100,
+			ImageURLs: []string{fmt.Sprintf("https://static.example/m%05d.jpg", i)},
+			SellerID:  "123456",
+			Created:   now.Add(-time.Duration(i) * time.Minute),
+			Updated:   now.Add(-time.Duration(i) * time.Minute),
+		}
+	}
+	return items
+}
+
+func newTestScanner(t *testing.T, srv *mercaritest.Server, opts ...mercari.ScannerOption) *mercari.Scanner {
+	t.Helper()
+	opts = append([]mercari.ScannerOption{mercari.WithBaseURL(srv.URL)}, opts...)
+	s, err := mercari.NewScanner(opts...)
+	if err != nil {
+		t.Fatalf("NewScanner: %v", err)
+	}
+	return s
+}
+
+func TestSearchFollowsPageTokens(t *testing.T) {
+	srv := mercaritest.NewServer(fixtureItems(25)...)
+	defer srv.Close()
+
+	s := newTestScanner(t, srv, mercari.WithMaxPages(5))
+	items, err := s.Search(context.Background(), mercari.SearchOptions{Keyword: "undercover", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	if len(items) != 25 {
+		t.Errorf("got %d items, want 25", len(items))
+	}
+	searches := srv.Searches()
+	if len(searches) != 3 {
+		t.Fatalf("got %d page requests, want 3", len(searches))
+	}
+	for i, req := range searches[1:] {
+		if req.PageToken == "" {
+			t.Errorf("page %d sent no page token", i+2)
+		}
+		if req.SearchSessionID != searches[0].SearchSessionID {
+			t.Errorf("page %d used a different search session", i+2)
+		}
+	}
+	if errs := srv.DPoPErrors(); len(errs) > 0 {
+		t.Errorf("DPoP rejected: %v", errs)
+	}
+}
+
+func TestSearchStopsAtPageCap(t *testing.T) {
+	srv := mercaritest.NewServer(fixtureItems(50)...)
+	defer srv.Close()
+
+	s := newTestScanner(t, srv, mercari.WithMaxPages(2))
+	items, err := s.Search(context.Background(), mercari.SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(items) != 20 || len(srv.Searches()) != 2 {
+		t.Errorf("got %d items in %d pages, want 20 in 2", len(items), len(srv.Searches()))
+	}
+}
+
+func TestSearchStopsAtMaxAge(t *testing.T) {
+	srv := mercaritest.NewServer(fixtureItems(50)...)
+	defer srv.Close()
+
+	s := newTestScanner(t, srv, mercari.WithMaxPages(10), mercari.WithMaxAge(15*time.Minute+30*time.Second))
+	items, err := s.Search(context.Background(), mercari.SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(items) != 16 {
+		t.Errorf("got %d items, want 16 (ages 0-15 min)", len(items))
+	}
+	if n := len(srv.Searches()); n != 2 {
+		t.Errorf("got %d page requests, want 2", n)
+	}
+}
+
+func TestSearchReportsHTTPErrors(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError} {
+		t.Run(fmt.Sprint(status), func(t *testing.T) {
+			srv := mercaritest.NewServer(fixtureItems(3)...)
+			defer srv.Close()
+			srv.FailNext(status, 1)
+
+			s := newTestScanner(t, srv)
+			_, err := s.Search(context.Background(), mercari.SearchOptions{Limit: 10})
+			if err == nil || !strings.Contains(err.Error(), fmt.Sprint(status)) {
+				t.Fatalf("got err %v, want one mentioning %d", err, status)
+			}
+
+			// The failure is consumed; the next call succeeds
+			if items, err := s.Search(context.Background(), mercari.SearchOptions{Limit: 10}); err != nil || len(items) != 3 {
+				t.Errorf("retry got %d items, err %v", len(items), err)
+			}
+		})
+	}
+}
+
+func TestSearchCancelled(t *testing.T) {
+	srv := mercaritest.NewServer(fixtureItems(3)...)
+	defer srv.Close()
+	s := newTestScanner(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Search(ctx, mercari.SearchOptions{Limit: 10}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got err %v, want context.Canceled", err)
+	}
+	if n := len(srv.Searches()); n != 0 {
+		t.Errorf("cancelled search still sent %d requests", n)
+	}
+}
+
+func TestEnrichItems(t *testing.T) {
+	fixtures := fixtureItems(2)
+	fixtures[0].Description = "未使用 タグ付き"
+	fixtures[0].Seller = "archive_shop"
+	fixtures[0].SellerStars = 5
+	fixtures[0].SellerRatings = 321
+	fixtures[0].Condition = "新品、未使用"
+	fixtures[0].Size = "M"
+	fixtures[0].ImageURLs = []string{"https://static.example/1.jpg", "https://static.example/2.jpg"}
+
+	srv := mercaritest.NewServer(fixtures...)
+	defer srv.Close()
+	s := newTestScanner(t, srv)
+
+	// The second item is unknown to the server; it must be kept as-is
+	missing := mercari.Item{ID: "m99999", Name: "gone"}
+	items := s.EnrichItems(context.Background(), []mercari.Item{{ID: fixtures[0].ID}, missing})
+
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	got := items[0]
+	if got.Description != "未使用 タグ付き" || got.Seller != "archive_shop" || got.SellerID != "123456" ||
+		got.SellerStars != 5 || got.SellerRatings != 321 || got.Condition != "新品、未使用" || got.Size != "M" {
+		t.Errorf("details not merged: %+v", got)
+	}
+	if len(got.ImageURLs) != 2 {
+		t.Errorf("got %d photos, want 2", len(got.ImageURLs))
+	}
+	if items[1].Name != "gone" {
+		t.Errorf("failed lookup changed the item: %+v", items[1])
+	}
+	if errs := srv.DPoPErrors(); len(errs) > 0 {
+		t.Errorf("DPoP rejected: %v", errs)
+	}
+}
@@ -0,0 +1,137 @@
+// Package notify defines the interface every alert destination implements
+// (Telegram, Discord, ...) and the deal payload they render.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/mercari"
+)
+
+// Notifier delivers bot alerts to one destination.
+type Notifier interface {
+	SendDeal(ctx context.Context, deal DealItem) error
+	SendStartup(ctx context.Context, brandCount int, scanInterval int) error
+	SendError(ctx context.Context, errMsg string) error
+	SendScanSummary(ctx context.Context, totalFound, totalNew, totalKept int, duration time.Duration) error
+}
+
+// DealItem holds the info needed to send a deal notification.
+type DealItem struct {
+	Name      string
+	Price     int
+	BrandName string
+	ImageURL  string
+	ItemURL   string
+	AgeMin    float64
+
+	// Optional details from the item page (empty if not fetched)
+	Description   string
+	Condition     string
+	Size          string
+	Seller        string
+	SellerStars   int
+	SellerRatings int
+
+	// Market value from sold listings; MarketMedian is 0 when unknown.
+	// BelowMarketPct is negative for items priced above the median.
+	MarketMedian   int
+	BelowMarketPct float64
+
+	// WatchedSeller is the label of the watched seller who posted the item,
+	// empty for regular brand deals.
+	WatchedSeller string
+
+	// PreviousPrice is set for price-drop alerts: the last price observed
+	// before this one. 0 for new listings.
+	PreviousPrice int
+
+	// Telegram routing: the brand's own chat and forum topic. Empty/0 use
+	// the notifier's default chat.
+	ChatID   string
+	ThreadID int
+
+	// Item is the full listing, for machine-readable destinations
+	Item mercari.Item
+}
+
+// ErrPartial wraps the error of a deal that reached some destinations but
+// not others. Callers should treat the deal as sent: retrying it would
+// repeat it where it already arrived.
+var ErrPartial = errors.New("notify: deal delivered to some destinations only")
+
+// Multi fans every alert out to several notifiers. A failing destination
+// does not stop the others; their errors are joined.
+type Multi []Notifier
+
+// SendDeal sends the deal to every notifier. If at least one of them
+// accepted it, any errors are wrapped with ErrPartial.
+func (m Multi) SendDeal(ctx context.Context, deal DealItem) error {
+	delivered := 0
+	err := m.each(func(n Notifier) error {
+		err := n.SendDeal(ctx, deal)
+		if err == nil {
+			delivered++
+		}
+		return err
+	})
+	if err != nil && delivered > 0 {
+		return fmt.Errorf("%w: %w", ErrPartial, err)
+	}
+	return err
+}
+
+// SendStartup sends the startup notice to every notifier.
+func (m Multi) SendStartup(ctx context.Context, brandCount int, scanInterval int) error {
+	return m.each(func(n Notifier) error { return n.SendStartup(ctx, brandCount, scanInterval) })
+}
+
+// SendError sends the error to every notifier.
+func (m Multi) SendError(ctx context.Context, errMsg string) error {
+	return m.each(func(n Notifier) error { return n.SendError(ctx, errMsg) })
+}
+
+// SendScanSummary sends the cycle summary to every notifier.
+func (m Multi) SendScanSummary(ctx context.Context, totalFound, totalNew, totalKept int, duration time.Duration) error {
+	return m.each(func(n Notifier) error { return n.SendScanSummary(ctx, totalFound, totalNew, totalKept, duration) })
+}
+
+func (m Multi) each(send func(Notifier) error) error {
+	var errs []error
+	for _, n := range m {
+		if err := send(n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FormatPrice formats a yen amount with thousand separators: 15000 → 15,000.
+func FormatPrice(price int) string {
+	s := fmt.Sprintf("%d", price)
+	if len(s) <= 3 {
+		return s
+	}
+	var result []byte
+	for i := 0; i < len(s); i++ {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			result = append(result, ',')
+		}
+		result = append(result, s[i])
+	}
+	return string(result)
+}
+
+// Truncate shortens s to at most n characters on a single line.
+func Truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
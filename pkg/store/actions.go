@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Tables behind the inline buttons on deal messages.
+const (
+	watchedItemsSchema = `
+		CREATE TABLE IF NOT EXISTS watched_items (
+			id          TEXT PRIMARY KEY,
+			brand       TEXT NOT NULL,
+			name        TEXT DEFAULT '',
+			price       INTEGER DEFAULT 0,
+			watched_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+	mutedBrandsSchema = `
+		CREATE TABLE IF NOT EXISTS muted_brands (
+			brand  TEXT PRIMARY KEY,
+			until  DATETIME NOT NULL
+		)
+	`
+	blockedSellersSchema = `
+		CREATE TABLE IF NOT EXISTS blocked_sellers (
+			seller_id   TEXT PRIMARY KEY,
+			item_id     TEXT DEFAULT '',
+			blocked_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+	filterFeedbackSchema = `
+		CREATE TABLE IF NOT EXISTS filter_feedback (
+			item_id     TEXT PRIMARY KEY,
+			brand       TEXT NOT NULL,
+			name        TEXT DEFAULT '',
+			verdict     TEXT NOT NULL,
+			created_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+)
+
+// FeedbackNotRelevant is the verdict recorded by the "🗑 Not relevant" button.
+const FeedbackNotRelevant = "not_relevant"
+
+// SeenItem is an announced (or suppressed) listing from seen_items.
+type SeenItem struct {
+	ID     string
+	Brand  string
+	Name   string
+	Price  int
+	SeenAt time.Time
+}
+
+// ErrNotFound is returned when an item is no longer tracked.
+var ErrNotFound = errors.New("not found")
+
+// GetSeen returns the stored record of an announced item.
+func (s *DedupStore) GetSeen(ctx context.Context, itemID string) (SeenItem, error) {
+	var it SeenItem
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, brand, name, price, seen_at FROM seen_items WHERE id = ?", itemID,
+	).Scan(&it.ID, &it.Brand, &it.Name, &it.Price, &it.SeenAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return it, ErrNotFound
+	}
+	if err != nil {
+		return it, fmt.Errorf("loading item %s: %w", itemID, err)
+	}
+	return it, nil
+}
+
+// Watch adds an item to the watch list.
+func (s *DedupStore) Watch(ctx context.Context, it SeenItem) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO watched_items (id, brand, name, price, watched_at) VALUES (?, ?, ?, ?, ?)",
+		it.ID, it.Brand, it.Name, it.Price, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("watching item %s: %w", it.ID, err)
+	}
+	return nil
+}
+
+// MuteBrand silences a brand until the given time.
+func (s *DedupStore) MuteBrand(ctx context.Context, brand string, until time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO muted_brands (brand, until) VALUES (?, ?)",
+		brand, until.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("muting brand %s: %w", brand, err)
+	}
+	return nil
+}
+
+// IsMuted reports whether a brand is currently muted.
+func (s *DedupStore) IsMuted(ctx context.Context, brand string) bool {
+	var count int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM muted_brands WHERE brand = ? AND until > ?", brand, time.Now().UTC(),
+	).Scan(&count)
+	if err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// BlockSeller hides a seller's listings from future scans.
+func (s *DedupStore) BlockSeller(ctx context.Context, sellerID, itemID string) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR IGNORE INTO blocked_sellers (seller_id, item_id, blocked_at) VALUES (?, ?, ?)",
+		sellerID, itemID, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("blocking seller %s: %w", sellerID, err)
+	}
+	return nil
+}
+
+// BlockedSellers returns the set of blocked seller IDs.
+func (s *DedupStore) BlockedSellers(ctx context.Context) (map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT seller_id FROM blocked_sellers")
+	if err != nil {
+		return nil, fmt.Errorf("listing blocked sellers: %w", err)
+	}
+	defer rows.Close()
+
+	blocked := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning blocked seller: %w", err)
+		}
+		blocked[id] = true
+	}
+	return blocked, rows.Err()
+}
+
+// RecordFeedback stores a user's verdict on an announced item, for tuning
+// the AI filter and keywords.
+func (s *DedupStore) RecordFeedback(ctx context.Context, it SeenItem, verdict string) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO filter_feedback (item_id, brand, name, verdict, created_at) VALUES (?, ?, ?, ?, ?)",
+		it.ID, it.Brand, it.Name, verdict, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("recording feedback for %s: %w", it.ID, err)
+	}
+	return nil
+}
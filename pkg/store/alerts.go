@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// alertMessagesSchema remembers the Telegram message behind each deal
+// alert, so it can be edited when the item sells or its price drops.
+const alertMessagesSchema = `
+	CREATE TABLE IF NOT EXISTS alert_messages (
+		item_id     TEXT NOT NULL,
+		chat_id     TEXT NOT NULL,
+		message_id  INTEGER NOT NULL,
+		photo       INTEGER DEFAULT 0,
+		caption     TEXT DEFAULT '',
+		seller_id   TEXT DEFAULT '',
+		price       INTEGER DEFAULT 0,
+		status      TEXT DEFAULT 'on_sale',
+		note        TEXT DEFAULT '',
+		sent_at     DATETIME DEFAULT CURRENT_TIMESTAMP,
+		checked_at  DATETIME,
+		PRIMARY KEY (chat_id, message_id)
+	)
+`
+
+// alertMessagesColumns are added to alert_messages tables created before
+// notes were kept.
+var alertMessagesColumns = []string{
+	"ALTER TABLE alert_messages ADD COLUMN note TEXT DEFAULT ''",
+}
+
+// Alert statuses.
+const (
+	AlertOnSale  = "on_sale"
+	AlertSold    = "sold"
+	AlertRemoved = "removed"
+)
+
+// AlertMessage is a delivered Telegram deal alert.
+type AlertMessage struct {
+	ItemID    string
+	ChatID    string
+	MessageID int
+	Photo     bool
+	Caption   string // original HTML caption
+	SellerID  string
+	Price     int    // last price shown in the message
+	Note      string // HTML note last added above the caption, if any
+	Status    string
+	SentAt    time.Time
+}
+
+// RecordAlert stores a delivered alert.
+func (s *DedupStore) RecordAlert(ctx context.Context, a AlertMessage) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO alert_messages
+			(item_id, chat_id, message_id, photo, caption, seller_id, price, status, sent_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.ItemID, a.ChatID, a.MessageID, a.Photo, a.Caption, a.SellerID, a.Price, AlertOnSale, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("recording alert for %s: %w", a.ItemID, err)
+	}
+	return nil
+}
+
+// RecentAlerts returns up to limit alerts still on sale that were sent
+// after since, least recently checked first.
+func (s *DedupStore) RecentAlerts(ctx context.Context, since time.Time, limit int) ([]AlertMessage, error) {
+	return s.queryAlerts(ctx, `
+		SELECT item_id, chat_id, message_id, photo, caption, seller_id, price, note, status, sent_at
+		FROM alert_messages
+		WHERE status = ? AND sent_at > ?
+		ORDER BY checked_at IS NOT NULL, checked_at, sent_at
+		LIMIT ?`,
+		AlertOnSale, since.UTC(), limit,
+	)
+}
+
+// WatchedAlerts returns up to limit alerts still on sale whose item is on
+// the watch list ("👀 Watch"), however old, least recently checked first.
+func (s *DedupStore) WatchedAlerts(ctx context.Context, limit int) ([]AlertMessage, error) {
+	return s.queryAlerts(ctx, `
+		SELECT a.item_id, a.chat_id, a.message_id, a.photo, a.caption, a.seller_id, a.price, a.note, a.status, a.sent_at
+		FROM alert_messages a JOIN watched_items w ON w.id = a.item_id
+		WHERE a.status = ?
+		ORDER BY a.checked_at IS NOT NULL, a.checked_at, a.sent_at
+		LIMIT ?`,
+		AlertOnSale, limit,
+	)
+}
+
+func (s *DedupStore) queryAlerts(ctx context.Context, query string, args ...interface{}) ([]AlertMessage, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var alerts []AlertMessage
+	for rows.Next() {
+		var a AlertMessage
+		if err := rows.Scan(&a.ItemID, &a.ChatID, &a.MessageID, &a.Photo, &a.Caption, &a.SellerID, &a.Price, &a.Note, &a.Status, &a.SentAt); err != nil {
+			return nil, fmt.Errorf("scanning alert: %w", err)
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// UpdateAlert records the outcome of a re-check: the item's status and the
+// price and note now shown in the message.
+func (s *DedupStore) UpdateAlert(ctx context.Context, chatID string, messageID int, status string, price int, note string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE alert_messages SET status = ?, price = ?, note = ?, checked_at = ? WHERE chat_id = ? AND message_id = ?",
+		status, price, note, time.Now().UTC(), chatID, messageID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating alert %s/%d: %w", chatID, messageID, err)
+	}
+	return nil
+}
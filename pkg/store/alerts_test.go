@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/store"
+)
+
+func TestCleanupKeepsWatchedAlerts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "seen.db")
+	st, err := store.NewDedupStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []string{"m1", "m2"} {
+		if err := st.RecordAlert(ctx, store.AlertMessage{ItemID: id, ChatID: "42", MessageID: i + 1, Price: 5000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Watch(ctx, store.SeenItem{ID: "m1", Brand: "Undercover", Name: "UNDERCOVER coat", Price: 5000}); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	// Both alerts were sent well before the cleanup cutoff
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE alert_messages SET sent_at = ?", time.Now().UTC().Add(-30*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Reopening cleans up
+	st, err = store.NewDedupStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	watched, err := st.WatchedAlerts(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watched) != 1 || watched[0].ItemID != "m1" {
+		t.Errorf("watched alerts after cleanup = %+v, want m1's", watched)
+	}
+	old, err := st.RecentAlerts(ctx, time.Time{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(old) != 1 {
+		t.Errorf("%d alerts left after cleanup, want only the watched one", len(old))
+	}
+}
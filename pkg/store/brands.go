@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// itemBrandsSchema records Mercari brands (itemBrand) seen in search results,
+// so brand IDs can be looked up by name later.
+const itemBrandsSchema = `
+	CREATE TABLE IF NOT EXISTS item_brands (
+		id         INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		hits       INTEGER DEFAULT 0,
+		last_seen  DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// BrandInfo is a Mercari brand observed in search results.
+type BrandInfo struct {
+	ID       int
+	Name     string
+	Hits     int // number of listings seen with this brand
+	LastSeen time.Time
+}
+
+// RecordBrand adds hits sightings of a Mercari brand.
+func (s *DedupStore) RecordBrand(ctx context.Context, id int, name string, hits int) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO item_brands (id, name, hits, last_seen) VALUES (?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			hits = hits + excluded.hits,
+			last_seen = excluded.last_seen`,
+		id, name, hits, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("recording brand %d: %w", id, err)
+	}
+	return nil
+}
+
+// LookupBrands returns recorded brands whose name contains query
+// (case-insensitive), most frequently seen first.
+func (s *DedupStore) LookupBrands(ctx context.Context, query string, limit int) ([]BrandInfo, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, hits, last_seen FROM item_brands
+		WHERE lower(name) LIKE ?
+		ORDER BY hits DESC, name
+		LIMIT ?`,
+		"%"+strings.ToLower(query)+"%", limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("looking up brands: %w", err)
+	}
+	defer rows.Close()
+
+	var brands []BrandInfo
+	for rows.Next() {
+		var b BrandInfo
+		if err := rows.Scan(&b.ID, &b.Name, &b.Hits, &b.LastSeen); err != nil {
+			return nil, fmt.Errorf("reading brand row: %w", err)
+		}
+		brands = append(brands, b)
+	}
+	return brands, rows.Err()
+}
@@ -6,9 +6,11 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -39,8 +41,8 @@ func NewDedupStore(dbPath string) (*DedupStore, error) {
 		}
 	}
 
-	// Create table
-	_, err = db.Exec(`
+	// Create tables
+	schema := []string{`
 		CREATE TABLE IF NOT EXISTS seen_items (
 			id       TEXT PRIMARY KEY,
 			brand    TEXT NOT NULL,
@@ -48,9 +50,20 @@ func NewDedupStore(dbPath string) (*DedupStore, error) {
 			price    INTEGER DEFAULT 0,
 			seen_at  DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
-	`)
-	if err != nil {
-		return nil, fmt.Errorf("creating table: %w", err)
+	`, itemBrandsSchema, soldPricesSchema, webhookFailuresSchema,
+		watchedItemsSchema, mutedBrandsSchema, blockedSellersSchema, filterFeedbackSchema,
+		brandOverlaySchema, botStateSchema, alertMessagesSchema, itemPricesSchema,
+		undeliveredDealsSchema}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("creating table: %w", err)
+		}
+	}
+	columns := append(append(itemPricesColumns, alertMessagesColumns...), webhookFailuresColumns...)
+	for _, stmt := range columns {
+		if _, err := db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			return nil, fmt.Errorf("adding column: %w", err)
+		}
 	}
 
 	store := &DedupStore{db: db}
@@ -62,9 +75,9 @@ func NewDedupStore(dbPath string) (*DedupStore, error) {
 }
 
 // HasSeen checks if an item ID has already been processed.
-func (s *DedupStore) HasSeen(itemID string) bool {
+func (s *DedupStore) HasSeen(ctx context.Context, itemID string) bool {
 	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM seen_items WHERE id = ?", itemID).Scan(&count)
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM seen_items WHERE id = ?", itemID).Scan(&count)
 	if err != nil {
 		log.Printf("[STORE] Error checking item %s: %v", itemID, err)
 		return false
@@ -73,8 +86,8 @@ func (s *DedupStore) HasSeen(itemID string) bool {
 }
 
 // MarkSeen records an item as processed.
-func (s *DedupStore) MarkSeen(itemID, brand, name string, price int) error {
-	_, err := s.db.Exec(
+func (s *DedupStore) MarkSeen(ctx context.Context, itemID, brand, name string, price int) error {
+	_, err := s.db.ExecContext(ctx,
 		"INSERT OR IGNORE INTO seen_items (id, brand, name, price, seen_at) VALUES (?, ?, ?, ?, ?)",
 		itemID, brand, name, price, time.Now().UTC(),
 	)
@@ -84,10 +97,18 @@ func (s *DedupStore) MarkSeen(itemID, brand, name string, price int) error {
 	return nil
 }
 
+// Unsee forgets that an item was processed, so it can be announced again.
+func (s *DedupStore) Unsee(ctx context.Context, itemID string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM seen_items WHERE id = ?", itemID); err != nil {
+		return fmt.Errorf("unmarking item seen: %w", err)
+	}
+	return nil
+}
+
 // Count returns the total number of seen items.
-func (s *DedupStore) Count() int {
+func (s *DedupStore) Count(ctx context.Context) int {
 	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM seen_items").Scan(&count)
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM seen_items").Scan(&count)
 	if err != nil {
 		return 0
 	}
@@ -106,6 +127,36 @@ func (s *DedupStore) cleanup() {
 	if rows > 0 {
 		log.Printf("[STORE] Cleaned up %d old entries", rows)
 	}
+
+	// Sold prices outside the market window are no longer used
+	if _, err := s.db.Exec("DELETE FROM sold_prices WHERE sold_at < ?", time.Now().UTC().Add(-2*marketWindow)); err != nil {
+		log.Printf("[STORE] Cleanup error: %v", err)
+	}
+
+	// Week-old webhook payloads are stale deals; stop replaying them
+	if _, err := s.db.Exec("DELETE FROM webhook_failures WHERE created_at < ?", cutoff); err != nil {
+		log.Printf("[STORE] Cleanup error: %v", err)
+	}
+
+	// Deals Telegram has not taken for a week are stale
+	if _, err := s.db.Exec("DELETE FROM undelivered_deals WHERE created_at < ?", cutoff); err != nil {
+		log.Printf("[STORE] Cleanup error: %v", err)
+	}
+
+	// Listings unseen for a week have most likely sold or expired
+	if _, err := s.db.Exec("DELETE FROM item_prices WHERE updated_at < ?", cutoff); err != nil {
+		log.Printf("[STORE] Cleanup error: %v", err)
+	}
+
+	// Alerts are only re-checked for a day or so, except those of watched
+	// items, for as long as they are on sale
+	if _, err := s.db.Exec(`
+		DELETE FROM alert_messages WHERE sent_at < ?
+		AND NOT (status = ? AND item_id IN (SELECT id FROM watched_items))`,
+		cutoff, AlertOnSale,
+	); err != nil {
+		log.Printf("[STORE] Cleanup error: %v", err)
+	}
 }
 
 // Close closes the database connection.
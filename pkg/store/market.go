@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// soldPricesSchema keeps sold listings per configured brand, used to
+// estimate market value. market_refresh remembers when each brand was
+// last refreshed.
+const soldPricesSchema = `
+	CREATE TABLE IF NOT EXISTS sold_prices (
+		item_id     TEXT PRIMARY KEY,
+		brand       TEXT NOT NULL,
+		category_id INTEGER DEFAULT 0,
+		price       INTEGER NOT NULL,
+		sold_at     DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_sold_prices_brand ON sold_prices (brand, category_id);
+	CREATE TABLE IF NOT EXISTS market_refresh (
+		brand        TEXT PRIMARY KEY,
+		refreshed_at DATETIME NOT NULL
+	)
+`
+
+// marketWindow is how far back sold prices count towards market stats.
+const marketWindow = 90 * 24 * time.Hour
+
+// MarketStats summarises sold prices for a brand (and optionally category).
+type MarketStats struct {
+	Count  int
+	P25    int
+	Median int
+	P75    int
+}
+
+// RecordSold stores a sold listing. Re-recording an item updates its price.
+func (s *DedupStore) RecordSold(ctx context.Context, itemID, brand string, categoryID, price int, soldAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sold_prices (item_id, brand, category_id, price, sold_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(item_id) DO UPDATE SET price = excluded.price, sold_at = excluded.sold_at`,
+		itemID, brand, categoryID, price, soldAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("recording sold item %s: %w", itemID, err)
+	}
+	return nil
+}
+
+// MarketStats returns sold-price percentiles for a brand over the last
+// 90 days. categoryID 0 covers all categories.
+func (s *DedupStore) MarketStats(ctx context.Context, brand string, categoryID int) (MarketStats, error) {
+	query := "SELECT price FROM sold_prices WHERE brand = ? AND sold_at >= ?"
+	args := []interface{}{brand, time.Now().UTC().Add(-marketWindow)}
+	if categoryID > 0 {
+		query += " AND category_id = ?"
+		args = append(args, categoryID)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return MarketStats{}, fmt.Errorf("querying sold prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []int
+	for rows.Next() {
+		var p int
+		if err := rows.Scan(&p); err != nil {
+			return MarketStats{}, fmt.Errorf("reading sold price: %w", err)
+		}
+		prices = append(prices, p)
+	}
+	if err := rows.Err(); err != nil {
+		return MarketStats{}, err
+	}
+	if len(prices) == 0 {
+		return MarketStats{}, nil
+	}
+
+	sort.Ints(prices)
+	return MarketStats{
+		Count:  len(prices),
+		P25:    percentile(prices, 25),
+		Median: percentile(prices, 50),
+		P75:    percentile(prices, 75),
+	}, nil
+}
+
+// MarketRefreshedAt returns when a brand's sold prices were last refreshed
+// (zero time if never).
+func (s *DedupStore) MarketRefreshedAt(ctx context.Context, brand string) time.Time {
+	var t time.Time
+	err := s.db.QueryRowContext(ctx, "SELECT refreshed_at FROM market_refresh WHERE brand = ?", brand).Scan(&t)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// MarkMarketRefreshed records that a brand's sold prices were refreshed now.
+func (s *DedupStore) MarkMarketRefreshed(ctx context.Context, brand string) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO market_refresh (brand, refreshed_at) VALUES (?, ?)",
+		brand, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("marking market refreshed: %w", err)
+	}
+	return nil
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []int, p int) int {
+	idx := (p*len(sorted)+99)/100 - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
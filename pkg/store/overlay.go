@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// brandOverlaySchema holds brands added or edited at runtime through
+// Telegram commands. Each row is a JSON document with the fields the
+// commands changed, merged onto the config entry of the same name.
+const brandOverlaySchema = `
+	CREATE TABLE IF NOT EXISTS brand_overlay (
+		name        TEXT PRIMARY KEY,
+		brand_json  TEXT NOT NULL,
+		updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// BrandOverlay is a runtime brand edit, stored as JSON.
+type BrandOverlay struct {
+	Name      string
+	JSON      []byte
+	UpdatedAt time.Time
+}
+
+// SaveBrandOverlay stores (or replaces) the runtime edit of a brand.
+func (s *DedupStore) SaveBrandOverlay(ctx context.Context, name string, brandJSON []byte) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO brand_overlay (name, brand_json, updated_at) VALUES (?, ?, ?)",
+		name, string(brandJSON), time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("saving brand overlay %s: %w", name, err)
+	}
+	return nil
+}
+
+// BrandOverlays returns every runtime brand edit, oldest first.
+func (s *DedupStore) BrandOverlays(ctx context.Context) ([]BrandOverlay, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name, brand_json, updated_at FROM brand_overlay ORDER BY updated_at, name")
+	if err != nil {
+		return nil, fmt.Errorf("listing brand overlays: %w", err)
+	}
+	defer rows.Close()
+
+	var overlays []BrandOverlay
+	for rows.Next() {
+		var o BrandOverlay
+		var data string
+		if err := rows.Scan(&o.Name, &data, &o.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning brand overlay: %w", err)
+		}
+		o.JSON = []byte(data)
+		overlays = append(overlays, o)
+	}
+	return overlays, rows.Err()
+}
+
+// BrandOverlay returns the runtime edit stored for a brand, if any.
+func (s *DedupStore) BrandOverlay(ctx context.Context, name string) (BrandOverlay, bool, error) {
+	o := BrandOverlay{Name: name}
+	var data string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT brand_json, updated_at FROM brand_overlay WHERE name = ?", name,
+	).Scan(&data, &o.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return BrandOverlay{}, false, nil
+	}
+	if err != nil {
+		return BrandOverlay{}, false, fmt.Errorf("loading brand overlay %s: %w", name, err)
+	}
+	o.JSON = []byte(data)
+	return o, true, nil
+}
+
+// DeleteBrandOverlay drops the runtime edit of a brand and reports whether
+// there was one.
+func (s *DedupStore) DeleteBrandOverlay(ctx context.Context, name string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM brand_overlay WHERE name = ?", name)
+	if err != nil {
+		return false, fmt.Errorf("deleting brand overlay %s: %w", name, err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// itemPricesSchema keeps the last observed price of every listing seen in
+// search results, announced or not, to spot price drops. source is the
+// brand (or watched seller) whose search last returned the listing;
+// updated_at is when a search last did, checked_at when the listing was
+// last looked up directly after searches stopped returning it.
+const itemPricesSchema = `
+	CREATE TABLE IF NOT EXISTS item_prices (
+		id          TEXT PRIMARY KEY,
+		price       INTEGER NOT NULL,
+		source      TEXT DEFAULT '',
+		updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+		checked_at  DATETIME
+	)
+`
+
+// itemPricesColumns are added to item_prices tables created before the
+// listings were re-checked.
+var itemPricesColumns = []string{
+	"ALTER TABLE item_prices ADD COLUMN source TEXT DEFAULT ''",
+	"ALTER TABLE item_prices ADD COLUMN checked_at DATETIME",
+}
+
+// TrackedPrice is a listing whose price is tracked for price drops.
+type TrackedPrice struct {
+	ItemID string
+	Source string
+	Price  int
+}
+
+// LastPrice returns the last observed price of an item; ok is false if the
+// item has not been seen before.
+func (s *DedupStore) LastPrice(ctx context.Context, itemID string) (price int, ok bool) {
+	err := s.db.QueryRowContext(ctx, "SELECT price FROM item_prices WHERE id = ?", itemID).Scan(&price)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("[STORE] Error loading price of %s: %v", itemID, err)
+		}
+		return 0, false
+	}
+	return price, true
+}
+
+// RecordPrice stores the price an item was seen at in the search results
+// of source.
+func (s *DedupStore) RecordPrice(ctx context.Context, itemID, source string, price int) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO item_prices (id, price, source, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET price = excluded.price, source = excluded.source, updated_at = excluded.updated_at`,
+		itemID, price, source, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("recording price of %s: %w", itemID, err)
+	}
+	return nil
+}
+
+// StalePrices returns up to limit tracked listings of the given sources
+// that no search has returned since seenBefore and that have not been
+// checked since checkedBefore, least recently checked first.
+func (s *DedupStore) StalePrices(ctx context.Context, sources []string, seenBefore, checkedBefore time.Time, limit int) ([]TrackedPrice, error) {
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{seenBefore.UTC(), checkedBefore.UTC()}
+	for _, source := range sources {
+		args = append(args, source)
+	}
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, source, price FROM item_prices
+		WHERE updated_at < ? AND (checked_at IS NULL OR checked_at < ?)
+		AND source IN (?`+strings.Repeat(", ?", len(sources)-1)+`)
+		ORDER BY checked_at IS NOT NULL, checked_at LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("loading stale prices: %w", err)
+	}
+	defer rows.Close()
+
+	var tracked []TrackedPrice
+	for rows.Next() {
+		var t TrackedPrice
+		if err := rows.Scan(&t.ItemID, &t.Source, &t.Price); err != nil {
+			return nil, fmt.Errorf("scanning stale price: %w", err)
+		}
+		tracked = append(tracked, t)
+	}
+	return tracked, rows.Err()
+}
+
+// CheckedPrice records the price a tracked listing was found at when
+// looked up directly. Unlike RecordPrice it leaves the listing stale.
+func (s *DedupStore) CheckedPrice(ctx context.Context, itemID string, price int) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE item_prices SET price = ?, checked_at = ? WHERE id = ?",
+		price, time.Now().UTC(), itemID,
+	)
+	if err != nil {
+		return fmt.Errorf("recording checked price of %s: %w", itemID, err)
+	}
+	return nil
+}
+
+// ForgetPrice stops tracking the price of a listing that sold or is gone.
+func (s *DedupStore) ForgetPrice(ctx context.Context, itemID string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM item_prices WHERE id = ?", itemID); err != nil {
+		return fmt.Errorf("forgetting price of %s: %w", itemID, err)
+	}
+	return nil
+}
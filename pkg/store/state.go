@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// botStateSchema is a small key/value table for bot state that must
+// survive restarts (e.g. a /pause).
+const botStateSchema = `
+	CREATE TABLE IF NOT EXISTS bot_state (
+		key         TEXT PRIMARY KEY,
+		value       TEXT NOT NULL,
+		updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// GetState returns the stored value for key, or "" if unset.
+func (s *DedupStore) GetState(ctx context.Context, key string) (string, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, "SELECT value FROM bot_state WHERE key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading state %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// SetState stores value under key; an empty value deletes the key.
+func (s *DedupStore) SetState(ctx context.Context, key, value string) error {
+	var err error
+	if value == "" {
+		_, err = s.db.ExecContext(ctx, "DELETE FROM bot_state WHERE key = ?", key)
+	} else {
+		_, err = s.db.ExecContext(ctx,
+			"INSERT OR REPLACE INTO bot_state (key, value, updated_at) VALUES (?, ?, ?)",
+			key, value, time.Now().UTC(),
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("writing state %s: %w", key, err)
+	}
+	return nil
+}
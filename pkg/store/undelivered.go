@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// undeliveredDealsSchema keeps deals Telegram could not take for the
+// moment, so they are resent on a later cycle, across restarts too. deal
+// is the JSON-encoded notify.DealItem.
+const undeliveredDealsSchema = `
+	CREATE TABLE IF NOT EXISTS undelivered_deals (
+		item_id     TEXT PRIMARY KEY,
+		source      TEXT NOT NULL,
+		deal        BLOB NOT NULL,
+		rounds      INTEGER DEFAULT 1,
+		last_error  TEXT DEFAULT '',
+		created_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// UndeliveredDeal is a deal waiting to be resent to Telegram.
+type UndeliveredDeal struct {
+	ItemID    string
+	Source    string
+	Deal      []byte
+	Rounds    int // delivery rounds tried so far
+	LastError string
+}
+
+// RecordUndelivered stores a deal whose delivery failed for resending.
+func (s *DedupStore) RecordUndelivered(ctx context.Context, itemID, source string, deal []byte, errMsg string) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO undelivered_deals (item_id, source, deal, last_error, created_at) VALUES (?, ?, ?, ?, ?)",
+		itemID, source, deal, errMsg, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("recording undelivered deal %s: %w", itemID, err)
+	}
+	return nil
+}
+
+// UndeliveredDeals returns up to limit deals waiting to be resent, oldest
+// first.
+func (s *DedupStore) UndeliveredDeals(ctx context.Context, limit int) ([]UndeliveredDeal, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT item_id, source, deal, rounds, last_error FROM undelivered_deals
+		ORDER BY created_at
+		LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing undelivered deals: %w", err)
+	}
+	defer rows.Close()
+
+	var deals []UndeliveredDeal
+	for rows.Next() {
+		var d UndeliveredDeal
+		if err := rows.Scan(&d.ItemID, &d.Source, &d.Deal, &d.Rounds, &d.LastError); err != nil {
+			return nil, fmt.Errorf("scanning undelivered deal: %w", err)
+		}
+		deals = append(deals, d)
+	}
+	return deals, rows.Err()
+}
+
+// RetryUndelivered records another failed delivery round of a deal.
+func (s *DedupStore) RetryUndelivered(ctx context.Context, itemID, errMsg string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE undelivered_deals SET rounds = rounds + 1, last_error = ? WHERE item_id = ?",
+		errMsg, itemID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating undelivered deal %s: %w", itemID, err)
+	}
+	return nil
+}
+
+// DeleteUndelivered removes a deal once it was delivered (or given up on).
+func (s *DedupStore) DeleteUndelivered(ctx context.Context, itemID string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM undelivered_deals WHERE item_id = ?", itemID); err != nil {
+		return fmt.Errorf("deleting undelivered deal %s: %w", itemID, err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// webhookFailuresSchema keeps webhook deliveries that exhausted their
+// retries, so they can be replayed on a later cycle. Rejected deliveries
+// (the receiver refused the payload) are only kept for inspection.
+const webhookFailuresSchema = `
+	CREATE TABLE IF NOT EXISTS webhook_failures (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		url         TEXT NOT NULL,
+		body        BLOB NOT NULL,
+		attempts    INTEGER DEFAULT 1,
+		last_error  TEXT DEFAULT '',
+		rejected    INTEGER DEFAULT 0,
+		created_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// webhookFailuresColumns are added to webhook_failures tables created
+// before rejections were kept apart.
+var webhookFailuresColumns = []string{
+	"ALTER TABLE webhook_failures ADD COLUMN rejected INTEGER DEFAULT 0",
+}
+
+// WebhookFailure is a webhook payload that could not be delivered.
+type WebhookFailure struct {
+	ID        int64
+	URL       string
+	Body      []byte
+	Attempts  int // delivery rounds tried so far
+	LastError string
+	CreatedAt time.Time
+}
+
+// RecordWebhookFailure stores an undelivered payload for later replay.
+func (s *DedupStore) RecordWebhookFailure(ctx context.Context, url string, body []byte, errMsg string) error {
+	now := time.Now().UTC()
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO webhook_failures (url, body, last_error, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		url, body, errMsg, now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("recording webhook failure: %w", err)
+	}
+	return nil
+}
+
+// RecordWebhookRejection stores a payload the receiver refused, for
+// inspection only; it is not replayed.
+func (s *DedupStore) RecordWebhookRejection(ctx context.Context, url string, body []byte, errMsg string) error {
+	now := time.Now().UTC()
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO webhook_failures (url, body, last_error, rejected, created_at, updated_at) VALUES (?, ?, ?, 1, ?, ?)",
+		url, body, errMsg, now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("recording webhook rejection: %w", err)
+	}
+	return nil
+}
+
+// WebhookFailures returns up to limit undelivered payloads for url that
+// are waiting for replay, oldest first.
+func (s *DedupStore) WebhookFailures(ctx context.Context, url string, limit int) ([]WebhookFailure, error) {
+	return s.webhookFailures(ctx, url, false, limit)
+}
+
+// RejectedWebhooks returns up to limit payloads the receiver at url
+// refused, oldest first.
+func (s *DedupStore) RejectedWebhooks(ctx context.Context, url string, limit int) ([]WebhookFailure, error) {
+	return s.webhookFailures(ctx, url, true, limit)
+}
+
+func (s *DedupStore) webhookFailures(ctx context.Context, url string, rejected bool, limit int) ([]WebhookFailure, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, url, body, attempts, last_error, created_at FROM webhook_failures
+		WHERE url = ? AND rejected = ?
+		ORDER BY id
+		LIMIT ?`,
+		url, rejected, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing webhook failures: %w", err)
+	}
+	defer rows.Close()
+
+	var failures []WebhookFailure
+	for rows.Next() {
+		var f WebhookFailure
+		if err := rows.Scan(&f.ID, &f.URL, &f.Body, &f.Attempts, &f.LastError, &f.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning webhook failure: %w", err)
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
+// RetryWebhookFailure records another failed replay of a stored payload.
+func (s *DedupStore) RetryWebhookFailure(ctx context.Context, id int64, errMsg string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE webhook_failures SET attempts = attempts + 1, last_error = ?, updated_at = ? WHERE id = ?",
+		errMsg, time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("updating webhook failure %d: %w", id, err)
+	}
+	return nil
+}
+
+// RejectWebhookFailure stops replaying a stored payload the receiver
+// refused, keeping it for inspection.
+func (s *DedupStore) RejectWebhookFailure(ctx context.Context, id int64, errMsg string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE webhook_failures SET attempts = attempts + 1, last_error = ?, rejected = 1, updated_at = ? WHERE id = ?",
+		errMsg, time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("rejecting webhook failure %d: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteWebhookFailure removes a payload once it was delivered (or given up on).
+func (s *DedupStore) DeleteWebhookFailure(ctx context.Context, id int64) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM webhook_failures WHERE id = ?", id); err != nil {
+		return fmt.Errorf("deleting webhook failure %d: %w", id, err)
+	}
+	return nil
+}
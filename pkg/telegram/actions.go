@@ -0,0 +1,149 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/xuhoa/autobot/pkg/notify"
+)
+
+// ActionKind identifies an inline button on a deal message. The values are
+// kept short because callback_data is limited to 64 bytes.
+type ActionKind string
+
+const (
+	ActionWatch       ActionKind = "w" // 👀 Watch
+	ActionMuteBrand   ActionKind = "m" // 🔇 Mute brand 24h
+	ActionBlockSeller ActionKind = "b" // 🚫 Block seller
+	ActionNotRelevant ActionKind = "n" // 🗑 Not relevant
+)
+
+// Action is a deal button pressed in one of the configured chats.
+type Action struct {
+	Kind     ActionKind
+	ItemID   string
+	SellerID string // only set for ActionBlockSeller
+}
+
+// Handlers answer what users send the bot.
+type Handlers struct {
+	// Status answers /check and /status.
+	Status func() string
+
+	// Action applies a pressed deal button and returns a short
+	// confirmation, shown to the user as a toast.
+	Action func(ctx context.Context, a Action) (string, error)
+
+	// Commands maps further command names (without the slash) to handlers.
+	Commands map[string]CommandFunc
+}
+
+// ---------- Telegram API structs ----------
+
+type inlineKeyboardMarkup struct {
+	InlineKeyboard [][]inlineKeyboardButton `json:"inline_keyboard"`
+}
+
+type inlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+type callbackQuery struct {
+	ID      string   `json:"id"`
+	Message *message `json:"message"`
+	Data    string   `json:"data"`
+}
+
+type answerCallbackRequest struct {
+	CallbackQueryID string `json:"callback_query_id"`
+	Text            string `json:"text,omitempty"`
+}
+
+// ---------- Buttons ----------
+
+// dealKeyboard returns the action buttons for a deal, or nil if the deal
+// carries no item ID to act on.
+func dealKeyboard(deal notify.DealItem) *inlineKeyboardMarkup {
+	return actionKeyboard(deal.Item.ID, deal.Item.SellerID)
+}
+
+func actionKeyboard(id, sellerID string) *inlineKeyboardMarkup {
+	if id == "" {
+		return nil
+	}
+	button := func(text string, a Action) inlineKeyboardButton {
+		return inlineKeyboardButton{Text: text, CallbackData: encodeAction(a)}
+	}
+
+	bottom := []inlineKeyboardButton{button("🗑 Not relevant", Action{Kind: ActionNotRelevant, ItemID: id})}
+	if sellerID != "" {
+		bottom = append([]inlineKeyboardButton{
+			button("🚫 Block seller", Action{Kind: ActionBlockSeller, ItemID: id, SellerID: sellerID}),
+		}, bottom...)
+	}
+	return &inlineKeyboardMarkup{InlineKeyboard: [][]inlineKeyboardButton{
+		{
+			button("👀 Watch", Action{Kind: ActionWatch, ItemID: id}),
+			button("🔇 Mute brand 24h", Action{Kind: ActionMuteBrand, ItemID: id}),
+		},
+		bottom,
+	}}
+}
+
+// encodeAction packs a as "<kind>:<itemID>[:<sellerID>]".
+func encodeAction(a Action) string {
+	data := string(a.Kind) + ":" + a.ItemID
+	if a.SellerID != "" {
+		data += ":" + a.SellerID
+	}
+	return data
+}
+
+func parseAction(data string) (Action, bool) {
+	parts := strings.Split(data, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+		return Action{}, false
+	}
+	a := Action{Kind: ActionKind(parts[0]), ItemID: parts[1]}
+	if len(parts) == 3 {
+		a.SellerID = parts[2]
+	}
+	switch a.Kind {
+	case ActionWatch, ActionMuteBrand, ActionNotRelevant:
+		return a, true
+	case ActionBlockSeller:
+		return a, a.SellerID != ""
+	}
+	return Action{}, false
+}
+
+// ---------- Callback handling ----------
+
+// handleCallback runs a pressed button through h.Action and answers the
+// query so the client stops its loading spinner.
+func (n *Notifier) handleCallback(ctx context.Context, q *callbackQuery, h Handlers) {
+	var reply string
+	switch a, ok := parseAction(q.Data); {
+	case q.Message == nil || q.Message.Chat == nil || !n.commandChat(fmt.Sprintf("%d", q.Message.Chat.ID)):
+		reply = "Not allowed here"
+	case !ok || h.Action == nil:
+		reply = "Unknown action"
+	default:
+		text, err := h.Action(ctx, a)
+		if err != nil {
+			log.Printf("[TELEGRAM] ⚠️ Action %s on %s failed: %v", a.Kind, a.ItemID, err)
+			text = "⚠️ " + err.Error()
+		}
+		reply = text
+	}
+
+	body, err := json.Marshal(answerCallbackRequest{CallbackQueryID: q.ID, Text: reply})
+	if err != nil {
+		return
+	}
+	_ = n.doRequest(ctx, n.apiBase+n.botToken+"/answerCallbackQuery", body, nil)
+}
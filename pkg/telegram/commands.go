@@ -0,0 +1,63 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// CommandFunc answers a chat command. args are the words after the command,
+// with quoted phrases kept together; the reply is sent as HTML.
+type CommandFunc func(ctx context.Context, args []string) string
+
+// parseCommand splits "/addkw@AutoBot "CDG Homme" デカロゴ" into
+// ("addkw", ["CDG Homme", "デカロゴ"]). ok is false for non-command text.
+func parseCommand(text string) (name string, args []string, ok bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", nil, false
+	}
+	fields := splitArgs(text[1:])
+	if len(fields) == 0 {
+		return "", nil, false
+	}
+	name = strings.ToLower(fields[0])
+	if at := strings.IndexByte(name, '@'); at >= 0 {
+		name = name[:at] // "/cmd@BotName" in group chats
+	}
+	return name, fields[1:], name != ""
+}
+
+// splitArgs splits s on whitespace, keeping "double", 'single' and
+// “smart” quoted phrases (as typed on phones) together.
+func splitArgs(s string) []string {
+	closing := map[rune]rune{'"': '"', '\'': '\'', '“': '”', '「': '」'}
+
+	var args []string
+	var cur strings.Builder
+	var quote rune // closing quote we are inside, 0 if none
+	inArg := false
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case closing[r] != 0 && !inArg:
+			quote, inArg = closing[r], true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
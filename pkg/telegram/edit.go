@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// SentDeal identifies a delivered deal message, so it can be edited later.
+type SentDeal struct {
+	ChatID    string
+	MessageID int
+	Photo     bool   // sent with sendPhoto (edit the caption, not the text)
+	Caption   string // original HTML caption
+	ItemID    string
+	SellerID  string
+	Price     int // price at the time of the alert
+}
+
+// WithOnSent calls fn after each deal is delivered, e.g. to store the
+// message ID for AnnotateDeal.
+func WithOnSent(fn func(ctx context.Context, m SentDeal)) NotifierOption {
+	return func(n *Notifier) {
+		n.onSent = fn
+	}
+}
+
+type editCaptionRequest struct {
+	ChatID      string                `json:"chat_id"`
+	MessageID   int                   `json:"message_id"`
+	Caption     string                `json:"caption"`
+	ParseMode   string                `json:"parse_mode"`
+	ReplyMarkup *inlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+type editTextRequest struct {
+	ChatID      string                `json:"chat_id"`
+	MessageID   int                   `json:"message_id"`
+	Text        string                `json:"text"`
+	ParseMode   string                `json:"parse_mode"`
+	ReplyMarkup *inlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+// AnnotateDeal rewrites a delivered deal with note (HTML) above the
+// original caption, e.g. "❌ <b>SOLD</b>". Sold deals lose their action
+// buttons; other notes keep them.
+func (n *Notifier) AnnotateDeal(ctx context.Context, m SentDeal, note string, sold bool) error {
+	caption := note + "\n\n" + m.Caption
+
+	var markup *inlineKeyboardMarkup
+	if !sold {
+		markup = actionKeyboard(m.ItemID, m.SellerID)
+	}
+
+	var method string
+	var req interface{}
+	if m.Photo {
+		method = "editMessageCaption"
+		req = editCaptionRequest{ChatID: m.ChatID, MessageID: m.MessageID, Caption: caption, ParseMode: "HTML", ReplyMarkup: markup}
+	} else {
+		method = "editMessageText"
+		req = editTextRequest{ChatID: m.ChatID, MessageID: m.MessageID, Text: caption, ParseMode: "HTML", ReplyMarkup: markup}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshaling %s request: %w", method, err)
+	}
+	return n.send(ctx, m.ChatID, method, body, nil)
+}
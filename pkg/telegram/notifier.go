@@ -6,63 +6,145 @@ package telegram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/xuhoa/autobot/pkg/clock"
+	"github.com/xuhoa/autobot/pkg/notify"
 )
 
-// Notifier sends deal alerts to Telegram.
+// Notifier sends deal alerts to Telegram. It implements notify.Notifier
+// and additionally answers /check commands from the configured chat.
 type Notifier struct {
 	botToken string
-	chatID   string
+	chatID   string // default destination
+	chatsMu  sync.RWMutex
+	chats    map[string]bool // chats allowed to send commands, see SetCommandChats
 	client   *http.Client
 	apiBase  string
+	onSent   func(ctx context.Context, m SentDeal) // see WithOnSent
+	queue    *sendQueue                            // paces and retries outgoing messages
+}
+
+// destination is a chat, optionally narrowed to a forum topic.
+type destination struct {
+	chatID   string
+	threadID int // message_thread_id; 0 = the chat's main thread
+}
+
+// NotifierOption configures optional Notifier behaviour.
+type NotifierOption func(*Notifier)
+
+// WithAPIBase points the notifier at a different Bot API host, e.g. a
+// local httptest server. base is the prefix the bot token is appended to
+// ("https://api.telegram.org/bot").
+func WithAPIBase(base string) NotifierOption {
+	return func(n *Notifier) {
+		n.apiBase = base
+	}
+}
+
+// WithCommandChats allows additional chats (e.g. per-brand chats) to send
+// commands. The default chat is always allowed.
+func WithCommandChats(chatIDs ...string) NotifierOption {
+	return func(n *Notifier) {
+		for _, id := range chatIDs {
+			if id != "" {
+				n.chats[id] = true
+			}
+		}
+	}
+}
+
+// SetCommandChats replaces the additional chats allowed to send commands,
+// e.g. after a config reload changed the brands' chats. The default chat
+// stays allowed.
+func (n *Notifier) SetCommandChats(chatIDs ...string) {
+	chats := map[string]bool{n.chatID: true}
+	for _, id := range chatIDs {
+		if id != "" {
+			chats[id] = true
+		}
+	}
+	n.chatsMu.Lock()
+	n.chats = chats
+	n.chatsMu.Unlock()
 }
 
-// NewNotifier creates a Telegram notifier.
-func NewNotifier(botToken, chatID string) *Notifier {
-	return &Notifier{
+// commandChat reports whether chatID may send commands.
+func (n *Notifier) commandChat(chatID string) bool {
+	n.chatsMu.RLock()
+	defer n.chatsMu.RUnlock()
+	return n.chats[chatID]
+}
+
+// NewNotifier creates a Telegram notifier. chatID is the default
+// destination, used unless a deal names its own chat.
+func NewNotifier(botToken, chatID string, opts ...NotifierOption) *Notifier {
+	n := &Notifier{
 		botToken: botToken,
 		chatID:   chatID,
+		chats:    map[string]bool{chatID: true},
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 		apiBase: "https://api.telegram.org/bot",
+		queue:   newSendQueue(),
+	}
+	for _, opt := range opts {
+		opt(n)
 	}
+	return n
 }
 
 // ---------- Telegram API request/response structs ----------
 
 type sendPhotoRequest struct {
-	ChatID    string `json:"chat_id"`
-	Photo     string `json:"photo"` // URL of the image
-	Caption   string `json:"caption"`
-	ParseMode string `json:"parse_mode"` // "HTML" or "MarkdownV2"
+	ChatID          string `json:"chat_id"`
+	MessageThreadID int    `json:"message_thread_id,omitempty"` // forum topic
+	Photo           string `json:"photo"`                       // URL of the image
+	Caption         string `json:"caption"`
+	ParseMode       string `json:"parse_mode"` // "HTML" or "MarkdownV2"
+
+	ReplyMarkup *inlineKeyboardMarkup `json:"reply_markup,omitempty"`
 }
 
 type sendMessageRequest struct {
-	ChatID    string `json:"chat_id"`
-	Text      string `json:"text"`
-	ParseMode string `json:"parse_mode"`
+	ChatID          string `json:"chat_id"`
+	MessageThreadID int    `json:"message_thread_id,omitempty"`
+	Text            string `json:"text"`
+	ParseMode       string `json:"parse_mode"`
+
+	ReplyMarkup *inlineKeyboardMarkup `json:"reply_markup,omitempty"`
 }
 
 type telegramResponse struct {
 	OK          bool            `json:"ok"`
+	ErrorCode   int             `json:"error_code,omitempty"`
 	Description string          `json:"description,omitempty"`
 	Result      json.RawMessage `json:"result,omitempty"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after,omitempty"` // seconds, on 429
+	} `json:"parameters,omitempty"`
 }
 
 type update struct {
-	UpdateID int      `json:"update_id"`
-	Message  *message `json:"message"`
+	UpdateID      int            `json:"update_id"`
+	Message       *message       `json:"message"`
+	CallbackQuery *callbackQuery `json:"callback_query"`
 }
 
 type message struct {
-	Chat *chat  `json:"chat"`
-	Text string `json:"text"`
+	Chat            *chat  `json:"chat"`
+	MessageThreadID int    `json:"message_thread_id"`
+	Text            string `json:"text"`
 }
 
 type chat struct {
@@ -71,28 +153,51 @@ type chat struct {
 
 // ---------- Public methods ----------
 
-// DealItem holds the info needed to send a deal notification.
-type DealItem struct {
-	Name      string
-	Price     int
-	BrandName string
-	ImageURL  string
-	ItemURL   string
-	AgeMin    float64
-}
-
-// SendDeal sends a formatted deal notification with product photo.
-func (n *Notifier) SendDeal(deal DealItem) error {
+// SendDeal sends a formatted deal notification with product photo and
+// action buttons, to the deal's own chat/topic if it has one and the
+// default chat otherwise. If the deal cannot be delivered for now (network
+// errors, 429, 5xx) the error wraps ErrUndelivered; if Telegram refused it
+// for good (chat not found, bad caption, ...) it wraps ErrRejected.
+func (n *Notifier) SendDeal(ctx context.Context, deal notify.DealItem) error {
 	caption := formatDealCaption(deal)
 
+	dest := n.defaultDest()
+	if deal.ChatID != "" {
+		dest.chatID = deal.ChatID
+	}
+	dest.threadID = deal.ThreadID
+
+	markup := dealKeyboard(deal)
+	var messageID int
+	var err error
 	if deal.ImageURL != "" {
-		return n.sendPhoto(deal.ImageURL, caption)
+		messageID, err = n.sendPhoto(ctx, dest, deal.ImageURL, caption, markup)
+	} else {
+		messageID, err = n.sendMessageMarkup(ctx, dest, caption, markup)
 	}
-	return n.sendMessage(caption)
+	if err != nil {
+		if !transient(err) {
+			return fmt.Errorf("%w: %w", ErrRejected, err)
+		}
+		return fmt.Errorf("%w: %w", ErrUndelivered, err)
+	}
+
+	if n.onSent != nil && deal.Item.ID != "" {
+		n.onSent(ctx, SentDeal{
+			ChatID:    dest.chatID,
+			MessageID: messageID,
+			Photo:     deal.ImageURL != "",
+			Caption:   caption,
+			ItemID:    deal.Item.ID,
+			SellerID:  deal.Item.SellerID,
+			Price:     deal.Price,
+		})
+	}
+	return nil
 }
 
 // SendStartup sends a startup notification.
-func (n *Notifier) SendStartup(brandCount int, scanInterval int) error {
+func (n *Notifier) SendStartup(ctx context.Context, brandCount int, scanInterval int) error {
 	msg := fmt.Sprintf(
 		"🤖 <b>AutoBot Started!</b>\n\n"+
 			"🔍 Watching <b>%d brands</b>\n"+
@@ -103,17 +208,17 @@ func (n *Notifier) SendStartup(brandCount int, scanInterval int) error {
 		scanInterval,
 		time.Now().Format("2006-01-02 15:04 MST"),
 	)
-	return n.sendMessage(msg)
+	return n.sendMessage(ctx, n.defaultDest(), msg)
 }
 
 // SendError sends an error notification (for critical errors only).
-func (n *Notifier) SendError(errMsg string) error {
-	msg := fmt.Sprintf("🔴 <b>AutoBot Error</b>\n\n<code>%s</code>", escapeHTML(errMsg))
-	return n.sendMessage(msg)
+func (n *Notifier) SendError(ctx context.Context, errMsg string) error {
+	msg := fmt.Sprintf("🔴 <b>AutoBot Error</b>\n\n<code>%s</code>", EscapeHTML(errMsg))
+	return n.sendMessage(ctx, n.defaultDest(), msg)
 }
 
 // SendScanSummary sends a summary after each scan cycle.
-func (n *Notifier) SendScanSummary(totalFound, totalNew, totalKept int, duration time.Duration) error {
+func (n *Notifier) SendScanSummary(ctx context.Context, totalFound, totalNew, totalKept int, duration time.Duration) error {
 	if totalNew == 0 {
 		return nil // don't spam if nothing new
 	}
@@ -124,59 +229,77 @@ func (n *Notifier) SendScanSummary(totalFound, totalNew, totalKept int, duration
 		totalFound, totalNew, totalKept,
 		duration.Round(time.Second),
 	)
-	return n.sendMessage(msg)
+	return n.sendMessage(ctx, n.defaultDest(), msg)
 }
 
 // TestConnection sends a test message to verify bot + chat ID work.
-func (n *Notifier) TestConnection() error {
+func (n *Notifier) TestConnection(ctx context.Context) error {
 	msg := "🧪 <b>AutoBot Test</b>\n\nTelegram connection successful! ✅"
-	return n.sendMessage(msg)
+	return n.sendMessage(ctx, n.defaultDest(), msg)
 }
 
-// ListenForCommands starts a long-polling loop to listen for /check commands.
-// It matches the specific chatID to prevent unauthorized access.
-func (n *Notifier) ListenForCommands(stopChan <-chan struct{}, getStatus func() string) {
+// ListenForCommands starts a long-polling loop answering /check, the other
+// commands in h and deal button presses until ctx is done. Only the
+// configured chats may issue commands, to prevent unauthorized access;
+// replies go back to the chat and topic asking.
+func (n *Notifier) ListenForCommands(ctx context.Context, h Handlers) {
 	offset := 0
 
 	for {
 		select {
-		case <-stopChan:
+		case <-ctx.Done():
 			return
 		default:
 			// Poll updates
-			updates, newOffset, err := n.getUpdates(offset)
+			updates, newOffset, err := n.getUpdates(ctx, offset)
 			if err != nil {
 				// Log error but verify it's not just a timeout
-				time.Sleep(5 * time.Second) // backoff
+				_ = clock.Sleep(ctx, 5*time.Second) // backoff
 				continue
 			}
 			offset = newOffset
 
 			for _, up := range updates {
+				if up.CallbackQuery != nil {
+					n.handleCallback(ctx, up.CallbackQuery, h)
+					continue
+				}
 				if up.Message == nil || up.Message.Text == "" {
 					continue
 				}
 
-				// Security check: only allow configured chatID
-				if fmt.Sprintf("%d", up.Message.Chat.ID) != n.chatID {
+				// Security check: only allow configured chats
+				from := destination{chatID: fmt.Sprintf("%d", up.Message.Chat.ID), threadID: up.Message.MessageThreadID}
+				if !n.commandChat(from.chatID) {
 					continue
 				}
 
-				if strings.HasPrefix(up.Message.Text, "/check") || strings.HasPrefix(up.Message.Text, "/status") {
-					statusMsg := getStatus()
-					_ = n.sendMessage(statusMsg)
+				name, args, ok := parseCommand(up.Message.Text)
+				if !ok {
+					continue
+				}
+				if name == "check" || name == "status" {
+					statusMsg := h.Status()
+					_ = n.sendMessage(ctx, from, statusMsg)
+				} else if cmd := h.Commands[name]; cmd != nil {
+					_ = n.sendMessage(ctx, from, cmd(ctx, args))
 				}
 			}
 
 			// Small sleep to prevent tight loops if polling is fast
-			time.Sleep(1 * time.Second)
+			_ = clock.Sleep(ctx, 1*time.Second)
 		}
 	}
 }
 
-func (n *Notifier) getUpdates(offset int) ([]update, int, error) {
-	url := fmt.Sprintf("%s%s/getUpdates?offset=%d&timeout=10", n.apiBase, n.botToken, offset)
-	resp, err := n.client.Get(url)
+func (n *Notifier) getUpdates(ctx context.Context, offset int) ([]update, int, error) {
+	reqURL := fmt.Sprintf("%s%s/getUpdates?offset=%d&timeout=10&allowed_updates=%s",
+		n.apiBase, n.botToken, offset, url.QueryEscape(`["message","callback_query"]`))
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, offset, err
+	}
+	resp, err := n.client.Do(req)
 	if err != nil {
 		return nil, offset, err
 	}
@@ -210,41 +333,69 @@ func (n *Notifier) getUpdates(offset int) ([]update, int, error) {
 
 // ---------- Private methods ----------
 
-func (n *Notifier) sendPhoto(photoURL, caption string) error {
+func (n *Notifier) defaultDest() destination {
+	return destination{chatID: n.chatID}
+}
+
+// sentMessage is the part of the Message object we read back.
+type sentMessage struct {
+	MessageID int `json:"message_id"`
+}
+
+func (n *Notifier) sendPhoto(ctx context.Context, dest destination, photoURL, caption string, markup *inlineKeyboardMarkup) (int, error) {
 	req := sendPhotoRequest{
-		ChatID:    n.chatID,
-		Photo:     photoURL,
-		Caption:   caption,
-		ParseMode: "HTML",
+		ChatID:          dest.chatID,
+		MessageThreadID: dest.threadID,
+		Photo:           photoURL,
+		Caption:         caption,
+		ParseMode:       "HTML",
+		ReplyMarkup:     markup,
 	}
 
 	body, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("marshaling photo request: %w", err)
+		return 0, fmt.Errorf("marshaling photo request: %w", err)
 	}
 
-	url := n.apiBase + n.botToken + "/sendPhoto"
-	return n.doRequest(url, body)
+	var msg sentMessage
+	err = n.send(ctx, dest.chatID, "sendPhoto", body, &msg)
+	return msg.MessageID, err
 }
 
-func (n *Notifier) sendMessage(text string) error {
+func (n *Notifier) sendMessage(ctx context.Context, dest destination, text string) error {
+	_, err := n.sendMessageMarkup(ctx, dest, text, nil)
+	return err
+}
+
+func (n *Notifier) sendMessageMarkup(ctx context.Context, dest destination, text string, markup *inlineKeyboardMarkup) (int, error) {
 	req := sendMessageRequest{
-		ChatID:    n.chatID,
-		Text:      text,
-		ParseMode: "HTML",
+		ChatID:          dest.chatID,
+		MessageThreadID: dest.threadID,
+		Text:            text,
+		ParseMode:       "HTML",
+		ReplyMarkup:     markup,
 	}
 
 	body, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("marshaling message request: %w", err)
+		return 0, fmt.Errorf("marshaling message request: %w", err)
 	}
 
-	url := n.apiBase + n.botToken + "/sendMessage"
-	return n.doRequest(url, body)
+	var msg sentMessage
+	err = n.send(ctx, dest.chatID, "sendMessage", body, &msg)
+	return msg.MessageID, err
 }
 
-func (n *Notifier) doRequest(url string, body []byte) error {
-	resp, err := n.client.Post(url, "application/json", bytes.NewReader(body))
+// doRequest posts body to a Bot API method URL and, if result is non-nil,
+// decodes the response's result field into it.
+func (n *Notifier) doRequest(ctx context.Context, url string, body []byte, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating telegram request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("telegram request failed: %w", err)
 	}
@@ -261,47 +412,80 @@ func (n *Notifier) doRequest(url string, body []byte) error {
 	}
 
 	if !tgResp.OK {
-		return fmt.Errorf("telegram API error: %s", tgResp.Description)
+		code := tgResp.ErrorCode
+		if code == 0 {
+			code = resp.StatusCode
+		}
+		return &APIError{
+			Code:        code,
+			Description: tgResp.Description,
+			RetryAfter:  time.Duration(tgResp.Parameters.RetryAfter) * time.Second,
+		}
 	}
 
+	if result != nil && len(tgResp.Result) > 0 {
+		if err := json.Unmarshal(tgResp.Result, result); err != nil {
+			return fmt.Errorf("parsing telegram result: %w", err)
+		}
+	}
 	return nil
 }
 
 // ---------- Formatting ----------
 
-func formatDealCaption(deal DealItem) string {
+func formatDealCaption(deal notify.DealItem) string {
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("🔥 <b>%s</b>\n", escapeHTML(deal.Name)))
-	sb.WriteString(fmt.Sprintf("💰 ¥%s\n", formatPrice(deal.Price)))
+	if deal.WatchedSeller != "" {
+		sb.WriteString(fmt.Sprintf("⭐ <b>Watched seller: %s</b>\n", EscapeHTML(deal.WatchedSeller)))
+	}
+	if deal.PreviousPrice > 0 {
+		sb.WriteString(fmt.Sprintf("💸 <b>Price drop: %s</b>\n", EscapeHTML(deal.Name)))
+		sb.WriteString(fmt.Sprintf("💰 <s>¥%s</s> → ¥%s\n", notify.FormatPrice(deal.PreviousPrice), notify.FormatPrice(deal.Price)))
+	} else {
+		sb.WriteString(fmt.Sprintf("🔥 <b>%s</b>\n", EscapeHTML(deal.Name)))
+		sb.WriteString(fmt.Sprintf("💰 ¥%s\n", notify.FormatPrice(deal.Price)))
+	}
+	if deal.MarketMedian > 0 {
+		if deal.BelowMarketPct >= 0 {
+			sb.WriteString(fmt.Sprintf("📉 %.0f%% below market (median ¥%s)\n", deal.BelowMarketPct, notify.FormatPrice(deal.MarketMedian)))
+		} else {
+			sb.WriteString(fmt.Sprintf("📈 %.0f%% above market (median ¥%s)\n", -deal.BelowMarketPct, notify.FormatPrice(deal.MarketMedian)))
+		}
+	}
 
 	if deal.BrandName != "" {
-		sb.WriteString(fmt.Sprintf("🏷 %s\n", escapeHTML(deal.BrandName)))
+		sb.WriteString(fmt.Sprintf("🏷 %s\n", EscapeHTML(deal.BrandName)))
+	}
+
+	if deal.Condition != "" {
+		sb.WriteString(fmt.Sprintf("✨ %s\n", EscapeHTML(deal.Condition)))
+	}
+	if deal.Size != "" {
+		sb.WriteString(fmt.Sprintf("📏 Size: %s\n", EscapeHTML(deal.Size)))
+	}
+	if deal.Seller != "" {
+		seller := EscapeHTML(deal.Seller)
+		if deal.SellerStars > 0 {
+			seller += " " + strings.Repeat("★", deal.SellerStars)
+		}
+		if deal.SellerRatings > 0 {
+			seller += fmt.Sprintf(" (%d reviews)", deal.SellerRatings)
+		}
+		sb.WriteString(fmt.Sprintf("👤 %s\n", seller))
 	}
 
 	sb.WriteString(fmt.Sprintf("📦 Posted %.0f min ago\n", deal.AgeMin))
+	if deal.Description != "" {
+		sb.WriteString(fmt.Sprintf("📝 <i>%s</i>\n", EscapeHTML(notify.Truncate(deal.Description, 150))))
+	}
 	sb.WriteString(fmt.Sprintf("🔗 <a href=\"%s\">View on Mercari</a>", deal.ItemURL))
 
 	return sb.String()
 }
 
-func formatPrice(price int) string {
-	// Format with thousand separator: 15000 → 15,000
-	s := fmt.Sprintf("%d", price)
-	if len(s) <= 3 {
-		return s
-	}
-	var result strings.Builder
-	for i, c := range s {
-		if i > 0 && (len(s)-i)%3 == 0 {
-			result.WriteByte(',')
-		}
-		result.WriteRune(c)
-	}
-	return result.String()
-}
-
-func escapeHTML(s string) string {
+// EscapeHTML escapes text for messages sent with parse_mode HTML.
+func EscapeHTML(s string) string {
 	s = strings.ReplaceAll(s, "&", "&amp;")
 	s = strings.ReplaceAll(s, "<", "&lt;")
 	s = strings.ReplaceAll(s, ">", "&gt;")
@@ -0,0 +1,160 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/clock"
+)
+
+// Bot API send limits, see
+// https://core.telegram.org/bots/faq#my-bot-is-hitting-limits-how-do-i-avoid-this
+const (
+	defaultGlobalGap = time.Second / 30 // ~30 messages per second overall
+	defaultChatGap   = time.Second      // 1 message per second per private chat
+	defaultGroupGap  = 3 * time.Second  // 20 messages per minute per group or channel
+
+	sendAttempts     = 4
+	defaultRetryWait = time.Second     // first backoff after a transient error, doubled after each
+	maxRetryAfter    = 2 * time.Minute // longer flood waits fail the send instead of blocking the scan
+)
+
+// ErrUndelivered wraps the error of a deal that could not be delivered
+// after retries, so the caller can queue it for the next cycle.
+var ErrUndelivered = errors.New("telegram: deal not delivered")
+
+// ErrRejected wraps the error of a deal Telegram refused for good (a 4xx
+// other than 429); resending it would fail the same way.
+var ErrRejected = errors.New("telegram: deal rejected")
+
+// APIError is an ok:false response from the Bot API.
+type APIError struct {
+	Code        int
+	Description string
+	RetryAfter  time.Duration // set on 429 Too Many Requests
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram API error %d: %s", e.Code, e.Description)
+}
+
+// transient reports whether a failed send may succeed if retried: network
+// errors, unreadable responses, 429 and 5xx. Other API errors (bad chat,
+// malformed caption, ...) will fail again.
+func transient(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.Code == 429 || apiErr.Code >= 500
+}
+
+// sendQueue hands out send slots in the order they are asked for, spacing
+// messages to stay within the global and per-chat limits. After a 429 it
+// holds every send back for as long as Telegram asked.
+type sendQueue struct {
+	globalGap time.Duration
+	chatGap   time.Duration
+	groupGap  time.Duration
+	retryWait time.Duration
+
+	mu       sync.Mutex
+	next     time.Time            // earliest slot for any message
+	chatNext map[string]time.Time // earliest slot per chat
+}
+
+func newSendQueue() *sendQueue {
+	return &sendQueue{
+		globalGap: defaultGlobalGap,
+		chatGap:   defaultChatGap,
+		groupGap:  defaultGroupGap,
+		retryWait: defaultRetryWait,
+		chatNext:  make(map[string]time.Time),
+	}
+}
+
+// WithSendLimits overrides the minimum spacing between any two messages,
+// between messages to one private chat and to one group or channel.
+// Zero disables a limit (e.g. against a local test server).
+func WithSendLimits(global, chat, group time.Duration) NotifierOption {
+	return func(n *Notifier) {
+		n.queue.globalGap, n.queue.chatGap, n.queue.groupGap = global, chat, group
+	}
+}
+
+// reserve books the next free slot for chatID and returns when it starts.
+func (q *sendQueue) reserve(chatID string) time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	slot := time.Now()
+	if q.next.After(slot) {
+		slot = q.next
+	}
+	if t := q.chatNext[chatID]; t.After(slot) {
+		slot = t
+	}
+
+	gap := q.chatGap
+	if strings.HasPrefix(chatID, "-") { // groups, supergroups and channels
+		gap = q.groupGap
+	}
+	q.next = slot.Add(q.globalGap)
+	q.chatNext[chatID] = slot.Add(gap)
+	return slot
+}
+
+// hold delays further sends to chatID, or to every chat if chatID is "",
+// by at least d from now.
+func (q *sendQueue) hold(chatID string, d time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if chatID == "" {
+		if until.After(q.next) {
+			q.next = until
+		}
+		return
+	}
+	if until.After(q.chatNext[chatID]) {
+		q.chatNext[chatID] = until
+	}
+}
+
+// send calls a message-sending Bot API method for chatID through the
+// queue, retrying transient failures and waiting out 429s.
+func (n *Notifier) send(ctx context.Context, chatID, method string, body []byte, result interface{}) error {
+	url := n.apiBase + n.botToken + "/" + method
+	wait := n.queue.retryWait
+
+	var err error
+	for attempt := 1; attempt <= sendAttempts; attempt++ {
+		if err := clock.Sleep(ctx, time.Until(n.queue.reserve(chatID))); err != nil {
+			return err
+		}
+		err = n.doRequest(ctx, url, body, result)
+		if err == nil || ctx.Err() != nil || !transient(err) {
+			return err
+		}
+
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			if apiErr.RetryAfter > maxRetryAfter {
+				return err
+			}
+			log.Printf("[TELEGRAM] ⏳ Rate limited on %s, waiting %s", method, apiErr.RetryAfter)
+			n.queue.hold("", apiErr.RetryAfter)
+			continue
+		}
+		log.Printf("[TELEGRAM] ⚠️ %s attempt %d/%d failed: %v", method, attempt, sendAttempts, err)
+		n.queue.hold(chatID, wait)
+		wait *= 2
+	}
+	return err
+}
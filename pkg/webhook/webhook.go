@@ -0,0 +1,363 @@
+// Package webhook POSTs deals as signed JSON documents to arbitrary HTTP
+// endpoints (spreadsheet scripts, buying queues, ...).
+//
+// Each request carries:
+//
+//	X-AutoBot-Event:     deal or price_drop
+//	X-AutoBot-Timestamp: unix seconds at send time
+//	X-AutoBot-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// Receivers should recompute the HMAC with the shared secret and reject
+// stale timestamps. Deliveries that still fail after retries are stored and
+// replayed by Replay on later cycles; until one gets through, new deals are
+// queued behind them without being attempted.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/clock"
+	"github.com/xuhoa/autobot/pkg/mercari"
+	"github.com/xuhoa/autobot/pkg/notify"
+	"github.com/xuhoa/autobot/pkg/store"
+)
+
+// PayloadVersion is bumped whenever the document layout changes incompatibly.
+const PayloadVersion = 1
+
+const (
+	defaultAttempts   = 3
+	defaultBackoff    = 2 * time.Second
+	replayBatch       = 20
+	maxReplayAttempts = 10
+)
+
+// FailureStore persists undelivered payloads. *store.DedupStore implements it.
+type FailureStore interface {
+	RecordWebhookFailure(ctx context.Context, url string, body []byte, errMsg string) error
+	RecordWebhookRejection(ctx context.Context, url string, body []byte, errMsg string) error
+	WebhookFailures(ctx context.Context, url string, limit int) ([]store.WebhookFailure, error)
+	RetryWebhookFailure(ctx context.Context, id int64, errMsg string) error
+	RejectWebhookFailure(ctx context.Context, id int64, errMsg string) error
+	DeleteWebhookFailure(ctx context.Context, id int64) error
+}
+
+// Notifier delivers deals to one webhook URL. It implements notify.Notifier;
+// only deals are sent, startup/error/summary messages are ignored.
+type Notifier struct {
+	url      string
+	secret   []byte
+	client   *http.Client
+	failures FailureStore // nil = drop failed deliveries
+	attempts int
+	backoff  time.Duration
+
+	// down is set when a delivery was queued for replay and cleared by the
+	// next successful POST; meanwhile deals are queued straight away.
+	down atomic.Bool
+}
+
+// NotifierOption configures optional Notifier behaviour.
+type NotifierOption func(*Notifier)
+
+// WithFailureStore records deliveries that exhaust their retries in fs.
+func WithFailureStore(fs FailureStore) NotifierOption {
+	return func(n *Notifier) {
+		n.failures = fs
+	}
+}
+
+// WithRetries sets how many times a delivery is attempted and the initial
+// backoff between attempts (doubled after each failure).
+func WithRetries(attempts int, backoff time.Duration) NotifierOption {
+	return func(n *Notifier) {
+		if attempts > 0 {
+			n.attempts = attempts
+		}
+		n.backoff = backoff
+	}
+}
+
+// NewNotifier creates a webhook notifier. secret may be empty, in which
+// case requests are not signed.
+func NewNotifier(url, secret string, opts ...NotifierOption) *Notifier {
+	n := &Notifier{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+		attempts: defaultAttempts,
+		backoff:  defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// ---------- Payload ----------
+
+// Payload is the JSON document POSTed for each deal.
+type Payload struct {
+	Version       int           `json:"version"`
+	Event         string        `json:"event"`
+	SentAt        time.Time     `json:"sent_at"`
+	Brand         string        `json:"brand"`
+	WatchedSeller string        `json:"watched_seller,omitempty"`
+	PreviousPrice int           `json:"previous_price,omitempty"` // price_drop only
+	Item          mercari.Item  `json:"item"`
+	Market        *MarketScore  `json:"market,omitempty"`
+	Filter        FilterVerdict `json:"filter"`
+}
+
+// MarketScore compares the price to recent sold listings.
+type MarketScore struct {
+	Median         int     `json:"median"`
+	BelowMarketPct float64 `json:"below_market_pct"`
+}
+
+// FilterVerdict is the AI filter's decision for the item.
+type FilterVerdict struct {
+	Verdict string  `json:"verdict"` // always "keep": trashed items are never sent
+	Label   string  `json:"label,omitempty"`
+	Score   float64 `json:"score"`
+}
+
+// Payload events.
+const (
+	EventDeal      = "deal"
+	EventPriceDrop = "price_drop" // a known listing got cheaper
+)
+
+func newPayload(deal notify.DealItem) Payload {
+	p := Payload{
+		Version:       PayloadVersion,
+		Event:         EventDeal,
+		SentAt:        time.Now().UTC(),
+		Brand:         deal.BrandName,
+		WatchedSeller: deal.WatchedSeller,
+		PreviousPrice: deal.PreviousPrice,
+		Item:          deal.Item,
+		Filter: FilterVerdict{
+			Verdict: "keep",
+			Label:   deal.Item.FilterLabel,
+			Score:   deal.Item.FilterScore,
+		},
+	}
+	if deal.PreviousPrice > 0 {
+		p.Event = EventPriceDrop
+	}
+	if deal.MarketMedian > 0 {
+		p.Market = &MarketScore{Median: deal.MarketMedian, BelowMarketPct: deal.BelowMarketPct}
+	}
+	return p
+}
+
+// ---------- Public methods ----------
+
+// SendDeal POSTs the deal, retrying with exponential backoff. If every
+// attempt fails and a failure store is configured, the payload is stored
+// for Replay and nil returned: the deal counts as delivered later. While
+// the endpoint is known to be down, deals are stored without being tried.
+// A payload the receiver refuses (4xx other than 408 and 429) is stored
+// for inspection only, never replayed.
+func (n *Notifier) SendDeal(ctx context.Context, deal notify.DealItem) error {
+	body, err := json.Marshal(newPayload(deal))
+	if err != nil {
+		return fmt.Errorf("marshaling webhook payload: %w", err)
+	}
+
+	if n.failures != nil && n.down.Load() {
+		return n.queue(ctx, body, "endpoint down, queued behind earlier failures")
+	}
+	err = n.deliver(ctx, body)
+	if err == nil || n.failures == nil {
+		return err
+	}
+	if rejected(err) {
+		log.Printf("[WEBHOOK] 🚫 %s rejected the payload, kept for inspection: %v", n.url, err)
+		if err := n.failures.RecordWebhookRejection(context.WithoutCancel(ctx), n.url, body, err.Error()); err != nil {
+			return fmt.Errorf("storing rejected webhook delivery: %w", err)
+		}
+		return nil
+	}
+	log.Printf("[WEBHOOK] ⚠️ Delivery to %s failed, queued for replay: %v", n.url, err)
+	n.down.Store(true)
+	return n.queue(ctx, body, err.Error())
+}
+
+// SendStartup is a no-op; webhooks only receive deals.
+func (n *Notifier) SendStartup(ctx context.Context, brandCount int, scanInterval int) error {
+	return nil
+}
+
+// SendError is a no-op; webhooks only receive deals.
+func (n *Notifier) SendError(ctx context.Context, errMsg string) error {
+	return nil
+}
+
+// SendScanSummary is a no-op; webhooks only receive deals.
+func (n *Notifier) SendScanSummary(ctx context.Context, totalFound, totalNew, totalKept int, duration time.Duration) error {
+	return nil
+}
+
+// Replay re-sends stored failed deliveries for this URL, oldest first.
+// Payloads are resent unchanged (with a fresh signature); ones that keep
+// failing are dropped after maxReplayAttempts rounds; ones the receiver
+// now refuses are kept for inspection and not replayed again.
+func (n *Notifier) Replay(ctx context.Context) (delivered, failed int) {
+	if n.failures == nil {
+		return 0, 0
+	}
+	pending, err := n.failures.WebhookFailures(ctx, n.url, replayBatch)
+	if err != nil {
+		log.Printf("[WEBHOOK] ⚠️ Could not load failed deliveries: %v", err)
+		return 0, 0
+	}
+
+	for _, f := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := n.post(ctx, f.Body); err != nil {
+			failed++
+			switch {
+			case rejected(err):
+				log.Printf("[WEBHOOK] 🚫 %s rejected delivery #%d, kept for inspection: %v", n.url, f.ID, err)
+				_ = n.failures.RejectWebhookFailure(ctx, f.ID, err.Error())
+			case f.Attempts+1 >= maxReplayAttempts:
+				log.Printf("[WEBHOOK] 🗑 Giving up on delivery #%d to %s after %d rounds: %v", f.ID, n.url, f.Attempts+1, err)
+				_ = n.failures.DeleteWebhookFailure(ctx, f.ID)
+			default:
+				_ = n.failures.RetryWebhookFailure(ctx, f.ID, err.Error())
+			}
+			continue
+		}
+		delivered++
+		_ = n.failures.DeleteWebhookFailure(ctx, f.ID)
+	}
+	if delivered > 0 {
+		n.down.Store(false)
+	}
+	if delivered+failed > 0 {
+		log.Printf("[WEBHOOK] 🔁 Replayed %s: %d delivered, %d still failing", n.url, delivered, failed)
+	}
+	return delivered, failed
+}
+
+// ---------- Private methods ----------
+
+// queue stores body for Replay.
+func (n *Notifier) queue(ctx context.Context, body []byte, errMsg string) error {
+	if err := n.failures.RecordWebhookFailure(context.WithoutCancel(ctx), n.url, body, errMsg); err != nil {
+		return fmt.Errorf("storing failed webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// deliver posts body up to n.attempts times, doubling the backoff after
+// each retryable failure.
+func (n *Notifier) deliver(ctx context.Context, body []byte) error {
+	backoff := n.backoff
+	var err error
+	for attempt := 1; attempt <= n.attempts; attempt++ {
+		if attempt > 1 {
+			if err := clock.Sleep(ctx, backoff); err != nil {
+				return err
+			}
+			backoff *= 2
+		}
+
+		err = n.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("[WEBHOOK] ⚠️ Attempt %d/%d to %s failed: %v", attempt, n.attempts, n.url, err)
+	}
+	return err
+}
+
+// statusError is a non-2xx response from the receiver.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("webhook HTTP %d: %s", e.code, e.body)
+}
+
+// retryable reports whether err may go away on retry: network errors,
+// 408, 429 and 5xx responses.
+func retryable(err error) bool {
+	se, ok := err.(*statusError)
+	if !ok {
+		return true
+	}
+	return se.code == http.StatusRequestTimeout || se.code == http.StatusTooManyRequests || se.code >= 500
+}
+
+// rejected reports whether the receiver refused the payload for good: any
+// other 4xx response (bad request, unauthorized, not found, ...).
+func rejected(err error) bool {
+	se, ok := err.(*statusError)
+	return ok && se.code < 500 && !retryable(err)
+}
+
+func (n *Notifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating webhook request: %w", err)
+	}
+	// The event is read back from the body so replays keep their header
+	event := struct {
+		Event string `json:"event"`
+	}{Event: EventDeal}
+	_ = json.Unmarshal(body, &event)
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AutoBot-Webhook/1")
+	req.Header.Set("X-AutoBot-Event", event.Event)
+	req.Header.Set("X-AutoBot-Timestamp", ts)
+	if len(n.secret) > 0 {
+		req.Header.Set("X-AutoBot-Signature", Sign(n.secret, ts, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{code: resp.StatusCode, body: string(respBody)}
+	}
+	return nil
+}
+
+// Sign returns the X-AutoBot-Signature value for body sent at timestamp ts.
+func Sign(secret []byte, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
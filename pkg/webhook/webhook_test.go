@@ -0,0 +1,184 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xuhoa/autobot/pkg/mercari"
+	"github.com/xuhoa/autobot/pkg/notify"
+	"github.com/xuhoa/autobot/pkg/store"
+	"github.com/xuhoa/autobot/pkg/webhook"
+)
+
+const secret = "s3cret"
+
+// receiver verifies signatures and fails while down is set.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	down     bool
+	attempts int
+	payloads []webhook.Payload
+	badSigs  int
+}
+
+func newReceiver() *receiver {
+	r := &receiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.attempts++
+		if r.down {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if req.Header.Get("X-AutoBot-Signature") != webhook.Sign([]byte(secret), req.Header.Get("X-AutoBot-Timestamp"), body) {
+			r.badSigs++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var p webhook.Payload
+		_ = json.Unmarshal(body, &p)
+		r.payloads = append(r.payloads, p)
+	}))
+	return r
+}
+
+func (r *receiver) setDown(down bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.down = down
+}
+
+func testDeal() notify.DealItem {
+	item := mercari.Item{
+		ID: "m123", Name: "UNDERCOVER jacket", Price: 12000, SellerID: "999",
+		ItemURL: "https://jp.mercari.com/item/m123", FilterLabel: "a jacket or coat", FilterScore: 0.87,
+	}
+	return notify.DealItem{
+		Name: item.Name, Price: item.Price, BrandName: "Undercover", ItemURL: item.ItemURL,
+		MarketMedian: 20000, BelowMarketPct: 40, Item: item,
+	}
+}
+
+func TestSendDealSignedPayload(t *testing.T) {
+	rcv := newReceiver()
+	defer rcv.Close()
+
+	n := webhook.NewNotifier(rcv.URL, secret)
+	if err := n.SendDeal(context.Background(), testDeal()); err != nil {
+		t.Fatalf("SendDeal: %v", err)
+	}
+	if rcv.badSigs != 0 || len(rcv.payloads) != 1 {
+		t.Fatalf("bad signatures %d, payloads %d", rcv.badSigs, len(rcv.payloads))
+	}
+	p := rcv.payloads[0]
+	if p.Version != webhook.PayloadVersion || p.Event != "deal" || p.Brand != "Undercover" {
+		t.Errorf("unexpected envelope: %+v", p)
+	}
+	if p.Item.ID != "m123" || p.Item.SellerID != "999" || p.Item.Price != 12000 {
+		t.Errorf("item fields missing: %+v", p.Item)
+	}
+	if p.Market == nil || p.Market.Median != 20000 || p.Filter.Label != "a jacket or coat" || p.Filter.Score != 0.87 {
+		t.Errorf("score/verdict missing: market=%+v filter=%+v", p.Market, p.Filter)
+	}
+}
+
+func TestFailedDeliveryIsReplayed(t *testing.T) {
+	rcv := newReceiver()
+	defer rcv.Close()
+	st, err := store.NewDedupStore(filepath.Join(t.TempDir(), "seen.db"))
+	if err != nil {
+		t.Fatalf("NewDedupStore: %v", err)
+	}
+	defer st.Close()
+
+	n := webhook.NewNotifier(rcv.URL, secret,
+		webhook.WithFailureStore(st), webhook.WithRetries(3, time.Millisecond))
+
+	// A queued delivery counts as sent, so the bot does not alert again
+	rcv.setDown(true)
+	if err := n.SendDeal(context.Background(), testDeal()); err != nil {
+		t.Fatalf("SendDeal returned %v after queueing the payload", err)
+	}
+	if rcv.attempts != 3 {
+		t.Errorf("made %d attempts, want 3", rcv.attempts)
+	}
+
+	// Later deals queue behind it without hitting the endpoint again
+	if err := n.SendDeal(context.Background(), testDeal()); err != nil {
+		t.Fatalf("second SendDeal: %v", err)
+	}
+	if rcv.attempts != 3 {
+		t.Errorf("made %d attempts while the endpoint was down, want 3", rcv.attempts)
+	}
+	if pending, _ := st.WebhookFailures(context.Background(), rcv.URL, 10); len(pending) != 2 {
+		t.Fatalf("stored %d failures, want 2", len(pending))
+	}
+
+	rcv.setDown(false)
+	if delivered, failed := n.Replay(context.Background()); delivered != 2 || failed != 0 {
+		t.Errorf("Replay delivered=%d failed=%d, want 2/0", delivered, failed)
+	}
+	if len(rcv.payloads) != 2 || rcv.payloads[0].Item.ID != "m123" {
+		t.Errorf("replayed payloads: %+v", rcv.payloads)
+	}
+	if pending, _ := st.WebhookFailures(context.Background(), rcv.URL, 10); len(pending) != 0 {
+		t.Errorf("%d failures left after replay", len(pending))
+	}
+}
+
+func TestRejectedDeliveryIsNotReplayed(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	st, err := store.NewDedupStore(filepath.Join(t.TempDir(), "seen.db"))
+	if err != nil {
+		t.Fatalf("NewDedupStore: %v", err)
+	}
+	defer st.Close()
+
+	n := webhook.NewNotifier(srv.URL, secret,
+		webhook.WithFailureStore(st), webhook.WithRetries(3, time.Millisecond))
+	if err := n.SendDeal(context.Background(), testDeal()); err != nil {
+		t.Fatalf("SendDeal returned %v after storing the rejection", err)
+	}
+	if attempts != 1 {
+		t.Errorf("made %d attempts at a 400, want 1", attempts)
+	}
+	if pending, _ := st.WebhookFailures(context.Background(), srv.URL, 10); len(pending) != 0 {
+		t.Errorf("rejected payload queued for replay: %d", len(pending))
+	}
+	if rejected, _ := st.RejectedWebhooks(context.Background(), srv.URL, 10); len(rejected) != 1 {
+		t.Errorf("stored %d rejected payloads, want 1", len(rejected))
+	}
+
+	// The endpoint is not considered down: the next deal is tried
+	_ = n.SendDeal(context.Background(), testDeal())
+	n.Replay(context.Background())
+	if attempts != 2 {
+		t.Errorf("made %d attempts in total, want 2", attempts)
+	}
+}
+
+func TestFailedDeliveryWithoutStoreReturnsError(t *testing.T) {
+	rcv := newReceiver()
+	defer rcv.Close()
+	rcv.setDown(true)
+
+	n := webhook.NewNotifier(rcv.URL, secret, webhook.WithRetries(2, time.Millisecond))
+	if err := n.SendDeal(context.Background(), testDeal()); err == nil {
+		t.Fatal("SendDeal succeeded against a failing receiver with nowhere to queue")
+	}
+}